@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+	"wc/wc"
+)
+
+// followed is a file counted as it grows
+type followed struct {
+	file    string
+	f       *os.File
+	counter *wc.Counter
+	offset  int64 // bytes read from f
+	printed bool  // the current counts were printed
+}
+
+// follow counts files and keeps them open, printing a row with the updated
+// counts of the files that grew every opts.interval, until interrupted.
+// A truncated file is counted again from its start and a rotated file,
+// when its name points to a new file, is replaced by the new one.
+func follow(files []string, width int, opts options) bool {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	ok := true
+
+	// the files outgrow the width computed from their current size
+	if opts.columns() > 1 {
+		width = max(width, 7)
+	}
+	p := newPrinter(os.Stdout, width, opts)
+
+	var watched []*followed
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			printError(file, err)
+			ok = false
+			continue
+		}
+
+		c, _ := newCounter(file, opts)
+		watched = append(watched, &followed{file: file, f: f, counter: c})
+	}
+	if len(watched) == 0 {
+		return ok
+	}
+
+	defer func() {
+		for _, w := range watched {
+			w.f.Close()
+		}
+	}()
+
+	ticker := time.NewTicker(opts.interval)
+	defer ticker.Stop()
+
+	for {
+		for _, w := range watched {
+			if err := w.poll(); err != nil {
+				printError(w.file, err)
+				ok = false
+			}
+
+			if !w.printed {
+				counts := w.counter.Counts()
+				p.row(w.file, &counts, nil)
+				w.printed = true
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ok
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll reads what was appended to the file since the last call, after
+// checking if it was truncated or rotated
+func (w *followed) poll() error {
+	if fi, err := os.Stat(w.file); err == nil {
+		cur, err := w.f.Stat()
+		if err == nil && !os.SameFile(fi, cur) {
+			f, err := os.Open(w.file)
+			if err == nil {
+				fmt.Fprintf(os.Stderr, "%s: %s: file replaced, following the new file\n", name, w.file)
+				w.f.Close()
+				w.f = f
+				w.restart()
+			}
+		}
+	}
+
+	fi, err := w.f.Stat()
+	if err != nil {
+		return err
+	}
+
+	if fi.Size() < w.offset {
+		fmt.Fprintf(os.Stderr, "%s: %s: file truncated\n", name, w.file)
+		if _, err := w.f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		w.restart()
+	}
+
+	n, err := w.counter.ReadFrom(w.f)
+	w.offset += n
+	if n > 0 {
+		w.printed = false
+	}
+
+	return err
+}
+
+// restart counts the file again from its start
+func (w *followed) restart() {
+	w.counter.Reset()
+	w.offset = 0
+	w.printed = false
+}
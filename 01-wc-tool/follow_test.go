@@ -0,0 +1,112 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFollowedPoll(t *testing.T) {
+	opts, _, err := parseArgs(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file := writeFile(t, "app.log", []byte("one two\n"))
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, _ := newCounter(file, opts)
+	w := &followed{file: file, f: f, counter: c}
+	defer func() { w.f.Close() }()
+
+	appendFile := func(data string) {
+		f, err := os.OpenFile(file, os.O_APPEND|os.O_WRONLY, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if _, err := f.WriteString(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the steps go on from the state of the previous one
+	steps := []struct {
+		name        string
+		change      func()
+		want        []int64 // lines, words and bytes
+		wantOffset  int64
+		wantPrinted bool
+	}{
+		{
+			name:       "first read",
+			change:     func() {},
+			want:       []int64{1, 2, 8},
+			wantOffset: 8,
+		},
+		{
+			name:        "unchanged",
+			change:      func() { w.printed = true },
+			want:        []int64{1, 2, 8},
+			wantOffset:  8,
+			wantPrinted: true,
+		},
+		{
+			name:       "grown",
+			change:     func() { w.printed = true; appendFile("three\nfour") },
+			want:       []int64{2, 4, 18},
+			wantOffset: 18,
+		},
+		{
+			name: "truncated",
+			change: func() {
+				if err := os.WriteFile(file, []byte("five\n"), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want:       []int64{1, 1, 5},
+			wantOffset: 5,
+		},
+		{
+			name: "replaced",
+			change: func() {
+				// like a log rotation, the name points to a new file
+				rotated := filepath.Join(filepath.Dir(file), "new.log")
+				if err := os.WriteFile(rotated, []byte("six seven eight\n"), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.Rename(rotated, file); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want:       []int64{1, 3, 16},
+			wantOffset: 16,
+		},
+		{
+			name:       "new file grown",
+			change:     func() { appendFile("nine\n") },
+			want:       []int64{2, 4, 21},
+			wantOffset: 21,
+		},
+	}
+	for _, step := range steps {
+		step.change()
+		if err := w.poll(); err != nil {
+			t.Fatalf("%s: poll() error = %v", step.name, err)
+		}
+
+		counts := w.counter.Counts()
+		if got := opts.values(&counts); !reflect.DeepEqual(got, step.want) {
+			t.Errorf("%s: counts = %v, want %v", step.name, got, step.want)
+		}
+		if w.offset != step.wantOffset {
+			t.Errorf("%s: offset = %d, want %d", step.name, w.offset, step.wantOffset)
+		}
+		if w.printed != step.wantPrinted {
+			t.Errorf("%s: printed = %v, want %v", step.name, w.printed, step.wantPrinted)
+		}
+	}
+}
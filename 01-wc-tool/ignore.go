@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"strings"
+)
+
+// ignoreRule is a .gitignore style pattern
+type ignoreRule struct {
+	segments []string // pattern split on "/"
+	negate   bool     // "!pattern" includes again what a previous rule excluded
+	dirOnly  bool     // "pattern/" only matches directories
+	anchored bool     // a "/" at the start or in the middle, the pattern is relative to the root
+}
+
+// ignoreList is a list of rules where the last matching one wins
+type ignoreList []ignoreRule
+
+// parseIgnore parses a .gitignore line, it returns false for blank lines
+// and comments
+func parseIgnore(line string) (ignoreRule, bool) {
+	var rule ignoreRule
+
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return rule, false
+	}
+
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	}
+	line = strings.TrimPrefix(line, `\`)
+
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+
+	if strings.Contains(line, "/") {
+		rule.anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+
+	if line == "" {
+		return rule, false
+	}
+
+	rule.segments = strings.Split(line, "/")
+
+	return rule, true
+}
+
+// readIgnoreFile parses the rules of a .gitignore like file
+func readIgnoreFile(file string) (ignoreList, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var list ignoreList
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if rule, ok := parseIgnore(scanner.Text()); ok {
+			list = append(list, rule)
+		}
+	}
+
+	return list, scanner.Err()
+}
+
+// match reports whether the rule matches rel, a slash separated path
+// relative to the root of the walk
+func (r ignoreRule) match(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+
+	if !r.anchored {
+		return matchSegments(r.segments, []string{path.Base(rel)})
+	}
+
+	return matchSegments(r.segments, strings.Split(rel, "/"))
+}
+
+// excluded reports whether rel is excluded by the list
+func (l ignoreList) excluded(rel string, isDir bool) bool {
+	excluded := false
+	for _, rule := range l {
+		if rule.match(rel, isDir) {
+			excluded = !rule.negate
+		}
+	}
+
+	return excluded
+}
+
+// matchSegments matches the path segments against the pattern ones, a "**"
+// pattern segment matches any number of path segments
+func matchSegments(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(segments); i++ {
+				if matchSegments(pattern[1:], segments[i:]) {
+					return true
+				}
+			}
+
+			return false
+		}
+
+		if len(segments) == 0 {
+			return false
+		}
+
+		if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+			return false
+		}
+
+		pattern, segments = pattern[1:], segments[1:]
+	}
+
+	return len(segments) == 0
+}
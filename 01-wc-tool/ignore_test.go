@@ -0,0 +1,80 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseIgnore(t *testing.T) {
+	tests := []struct {
+		name   string
+		line   string
+		want   ignoreRule
+		wantOk bool
+	}{
+		{name: "name", line: "*.log", want: ignoreRule{segments: []string{"*.log"}}, wantOk: true},
+		{name: "negation", line: "!keep.log", want: ignoreRule{segments: []string{"keep.log"}, negate: true}, wantOk: true},
+		{name: "escaped negation", line: `\!important`, want: ignoreRule{segments: []string{"!important"}}, wantOk: true},
+		{name: "directory", line: "build/", want: ignoreRule{segments: []string{"build"}, dirOnly: true}, wantOk: true},
+		{name: "anchored at the root", line: "/vendor", want: ignoreRule{segments: []string{"vendor"}, anchored: true}, wantOk: true},
+		{name: "anchored by a middle slash", line: "docs/*.md", want: ignoreRule{segments: []string{"docs", "*.md"}, anchored: true}, wantOk: true},
+		{name: "trailing blanks", line: "a.txt \t\r", want: ignoreRule{segments: []string{"a.txt"}}, wantOk: true},
+		{name: "blank line", line: "   "},
+		{name: "comment", line: "# *.log"},
+		{name: "root alone", line: "/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseIgnore(tt.line)
+			if ok != tt.wantOk {
+				t.Fatalf("parseIgnore() ok = %v, want %v", ok, tt.wantOk)
+			}
+			if ok && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseIgnore() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIgnoreListExcluded(t *testing.T) {
+	file := writeFile(t, ".gitignore", []byte("# build outputs\n*.log\n!keep.log\nbuild/\n/vendor\ndocs/*.md\n**/testdata/**\na/**/z.txt\n"))
+
+	list, err := readIgnoreFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		rel   string
+		isDir bool
+		want  bool
+	}{
+		{name: "name in any directory", rel: "src/debug.log", want: true},
+		{name: "negated name", rel: "src/keep.log"},
+		{name: "other name", rel: "src/main.go"},
+		{name: "directory", rel: "src/build", isDir: true, want: true},
+		{name: "file named like a directory rule", rel: "src/build"},
+		{name: "anchored at the root", rel: "vendor", isDir: true, want: true},
+		{name: "anchored below the root", rel: "src/vendor", isDir: true},
+		{name: "anchored glob", rel: "docs/intro.md", want: true},
+		{name: "anchored glob one level down", rel: "docs/api/intro.md"},
+		{name: "anchored glob elsewhere", rel: "src/docs/intro.md"},
+		{name: "double star directory", rel: "pkg/lib/testdata/in.txt", want: true},
+		{name: "double star directory at the root", rel: "testdata/in.txt", want: true},
+		{name: "double star in the middle", rel: "a/b/c/z.txt", want: true},
+		{name: "double star matching no directory", rel: "a/z.txt", want: true},
+		{name: "double star with another base", rel: "b/c/z.txt"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := list.excluded(tt.rel, tt.isDir); got != tt.want {
+				t.Errorf("excluded(%q, %v) = %v, want %v", tt.rel, tt.isDir, got, tt.want)
+			}
+		})
+	}
+
+	if _, err := readIgnoreFile(file + ".missing"); err == nil {
+		t.Error("readIgnoreFile() error = nil, want an error")
+	}
+}
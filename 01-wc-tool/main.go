@@ -1,134 +1,270 @@
 package main
 
 import (
-	"bufio"
+	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
-	"strings"
+	"slices"
+	"strconv"
+	"wc/wc"
 )
 
+const name = "ccwc"
+
+// maxInvalidReports is the number of invalid byte sequences printed per file
+const maxInvalidReports = 10
+
 func main() {
-	var (
-		cmd     string
-		file    string
-		scanner *bufio.Scanner
-		b       bool
-		l       bool
-		w       bool
-		c       bool
-	)
-
-	switch len(os.Args) {
-    case 1:
-        cmd = ""
-        file = ""
-
-	case 2:
-		if strings.HasPrefix(os.Args[1], "-") {
-			cmd = os.Args[1]
-		} else {
-			file = os.Args[1]
+	opts, files, err := parseArgs(os.Args[1:])
+	if errors.Is(err, errHelp) {
+		fmt.Print(usage)
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\nTry '%s --help' for more information.\n", name, err, name)
+		os.Exit(1)
+	}
+
+	if opts.files0From != "" {
+		files, err = readFiles0(opts.files0From)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+			os.Exit(1)
+		}
+		if len(files) == 0 {
+			return
 		}
+	}
 
-	case 3:
-		cmd = os.Args[1]
-		file = os.Args[2]
+	ok := true
+	if opts.recursive {
+		if len(files) == 0 {
+			files = []string{"."}
+		}
 
-	default:
-		println("invalid usage!")
-		return
+		files, ok = expandFiles(files, opts)
+		if len(files) == 0 {
+			if !ok {
+				os.Exit(1)
+			}
+			return
+		}
 	}
 
-	if file != "" {
-		fileExists(file)
+	width := numberWidth(files, opts)
+	if opts.files0From != "" && !isRegular(opts.files0From) {
+		// like GNU wc, names coming from a pipe are not known in advance,
+		// so the columns are not aligned
+		width = 1
+	}
 
-		f, err := os.OpenFile(file, os.O_RDONLY, os.ModePerm)
-		if err != nil {
-			fmt.Printf("open file error: %v\n", err)
+	if opts.follow {
+		if len(files) == 0 {
+			fmt.Fprintf(os.Stderr, "%s: --follow needs file operands\n", name)
 			os.Exit(1)
 		}
-		defer f.Close()
 
-		reader := bufio.NewReader(f)
-		scanner = bufio.NewScanner(reader)
+		ok = follow(files, width, opts) && ok
 	} else {
-		scanner = bufio.NewScanner(os.Stdin)
+		ok = run(files, width, opts) && ok
 	}
 
-	switch cmd {
-	case "-b":
-		b = true
+	if !ok {
+		os.Exit(1)
+	}
+}
 
-	case "-l":
-		l = true
+// run counts every file and prints one row per file, in the order of the
+// arguments, or one row per extension, followed by the totals and the most
+// frequent words. A failing
+// file is reported and does not stop the others, run returns false if any
+// of them failed.
+func run(files []string, width int, opts options) bool {
+	var total wc.Counts
+	ok := true
 
-	case "-w":
-		w = true
+	type group struct {
+		files  int64
+		counts wc.Counts
+	}
+	groups := make(map[string]*group)
+	counted := 0
 
-	case "-c":
-		c = true
+	var freq map[string]int64
+	if opts.top > 0 {
+		freq = make(map[string]int64)
+	}
 
-	default:
-		b = true
-		l = true
-		w = true
-		c = true
+	if len(files) == 0 {
+		files = []string{""}
 	}
 
-	count(scanner, b, l, w, c)
+	p := newPrinter(os.Stdout, width, opts)
 
-	fmt.Printf("%s\n", file)
-}
+	for i, res := range countAll(files, opts) {
+		r := <-res
+		if r.err != nil {
+			ok = false
+		}
 
-func count(scanner *bufio.Scanner, b, l, w, c bool) {
-	var (
-		s     string
-		bSize int
-		lSize int
-		wSize int
-		cSize int
-	)
+		var counts *wc.Counts
+		if r.counter != nil {
+			c := r.counter.Counts()
+			counts = &c
+			total.Add(c)
 
-	for scanner.Scan() {
-        buf := scanner.Bytes()
+			for word, n := range r.counter.WordFrequencies() {
+				freq[word] += n
+			}
+		}
 
-		if l {
-			lSize += 1
+		if !opts.byExt {
+			p.row(files[i], counts, r.err)
+			continue
 		}
 
-		s += string(buf)
+		if r.err != nil {
+			printError(files[i], r.err)
+		}
+		if counts != nil {
+			counted++
+			ext := extension(files[i])
+			if groups[ext] == nil {
+				groups[ext] = new(group)
+			}
+			groups[ext].files++
+			groups[ext].counts.Add(*counts)
+		}
 	}
-	if err := scanner.Err(); err != nil {
-		panic(err)
+
+	exts := make([]string, 0, len(groups))
+	for ext := range groups {
+		exts = append(exts, ext)
 	}
+	slices.Sort(exts)
 
-	if b {
-		bSize = len(s)
-		fmt.Printf("%d ", bSize)
+	for _, ext := range exts {
+		p.group(ext, groups[ext].files, groups[ext].counts)
 	}
 
-	if l {
-		fmt.Printf("%d ", lSize)
+	var top []wc.WordCount
+	if opts.top > 0 {
+		top = wc.TopWords(freq, opts.top)
 	}
 
-	if w {
-        wSize = len(strings.Fields(s))
-		fmt.Printf("%d ", wSize)
+	// the summary by extension only counts the files that could be read
+	n := len(files)
+	if opts.byExt {
+		n = counted
 	}
 
-	if c {
-        cSize += len([]rune(s))
-		fmt.Printf("%d ", cSize)
+	if err := p.total(total, n, top); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+		return false
 	}
+
+	return ok
 }
 
-func fileExists(file string) fs.FileInfo {
-	fs, err := os.Stat(file)
-	if os.IsNotExist(err) {
-		println("file do not exists")
-		os.Exit(1)
+// countFile counts the given file, an empty name or "-" is the standard input.
+// When the file could not be opened the returned counter is nil.
+func countFile(file string, opts options) (*wc.Counter, error) {
+	var r io.Reader = os.Stdin
+
+	if file != "" && file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		r = f
+	}
+
+	c, done := newCounter(file, opts)
+
+	_, err := c.ReadFrom(r)
+	c.Close()
+	done()
+
+	return c, err
+}
+
+// newCounter returns the counter of file. When the encoding is checked the
+// first invalid sequences are reported, the returned function reports how
+// many more there were once the file is counted.
+func newCounter(file string, opts options) (*wc.Counter, func()) {
+	c := &wc.Counter{
+		Encoding: opts.encoding,
+		WordFreq: opts.top > 0,
+		FoldCase: opts.ignoreCase,
+	}
+	if !opts.checkEncoding {
+		return c, func() {}
+	}
+
+	// only the first invalid sequences are printed, binary files have plenty
+	var invalid int
+	c.Invalid = func(offset int64, seq []byte) {
+		invalid++
+		if invalid <= maxInvalidReports {
+			fmt.Fprintf(os.Stderr, "%s: %s: invalid byte sequence %x at offset %d\n", name, displayName(file), seq, offset)
+		}
+	}
+
+	return c, func() {
+		if invalid > maxInvalidReports {
+			fmt.Fprintf(os.Stderr, "%s: %s: %d more invalid byte sequences\n", name, displayName(file), invalid-maxInvalidReports)
+		}
+	}
+}
+
+// numberWidth returns the column width used for every row, following the
+// GNU wc rule: enough digits for the total size of the regular files, at
+// least 7 when a non regular file (like a pipe) is read, and no padding at
+// all when a single counter of a single file is printed
+func numberWidth(files []string, opts options) int {
+	if len(files) <= 1 && opts.columns() == 1 {
+		return 1
 	}
 
-	return fs
+	minimum := 1
+	var size int64
+
+	if len(files) == 0 {
+		files = []string{""}
+	}
+
+	for _, file := range files {
+		fi, err := stat(file)
+		if err != nil {
+			continue
+		}
+
+		if fi.Mode().IsRegular() {
+			size += fi.Size()
+		} else {
+			minimum = 7
+		}
+	}
+
+	return max(len(strconv.FormatInt(size, 10)), minimum)
+}
+
+// stat returns the file info of file, an empty name or "-" is the standard input
+func stat(file string) (fs.FileInfo, error) {
+	if file == "" || file == "-" {
+		return os.Stdin.Stat()
+	}
+
+	return os.Stat(file)
+}
+
+// isRegular reports whether file is a regular file
+func isRegular(file string) bool {
+	fi, err := stat(file)
+
+	return err == nil && fi.Mode().IsRegular()
 }
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeFile writes data to a new file of the test and returns its name
+func writeFile(t *testing.T, name string, data []byte) string {
+	t.Helper()
+
+	file := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(file, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	return file
+}
+
+// runOutput calls run with the standard output redirected, it returns what
+// was printed
+func runOutput(t *testing.T, files []string, opts options) (string, bool) {
+	t.Helper()
+
+	out, err := os.Create(filepath.Join(t.TempDir(), "stdout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+
+	saved := os.Stdout
+	os.Stdout = out
+	ok := run(files, numberWidth(files, opts), opts)
+	os.Stdout = saved
+
+	buf, err := os.ReadFile(out.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(buf), ok
+}
+
+func TestRun(t *testing.T) {
+	a := writeFile(t, "a.txt", []byte("one two\nthree\n"))
+	b := writeFile(t, "b.txt", []byte("four\n"))
+	missing := filepath.Join(t.TempDir(), "missing.txt")
+
+	opts, _, err := parseArgs(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		files  []string
+		want   string
+		wantOk bool
+	}{
+		{
+			name:   "single file",
+			files:  []string{a},
+			want:   fmt.Sprintf(" 2  3 14 %s\n", a),
+			wantOk: true,
+		},
+		{
+			name:   "files and total",
+			files:  []string{a, b},
+			want:   fmt.Sprintf(" 2  3 14 %s\n 1  1  5 %s\n 3  4 19 total\n", a, b),
+			wantOk: true,
+		},
+		{
+			name:  "missing file",
+			files: []string{a, missing, b},
+			want:  fmt.Sprintf(" 2  3 14 %s\n 1  1  5 %s\n 3  4 19 total\n", a, b),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := runOutput(t, tt.files, opts)
+			if got != tt.want {
+				t.Errorf("run() printed %q, want %q", got, tt.want)
+			}
+			if ok != tt.wantOk {
+				t.Errorf("run() = %v, want %v", ok, tt.wantOk)
+			}
+		})
+	}
+}
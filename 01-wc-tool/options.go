@@ -0,0 +1,415 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"wc/wc"
+)
+
+const usage = `Usage: ccwc [OPTION]... [FILE]...
+  or:  ccwc [OPTION]... --files0-from=F
+Print newline, word, and byte counts for each FILE, and a total line if
+more than one FILE is specified.
+
+With no FILE, or when FILE is -, read standard input.
+
+The counts are always printed in the following order: newline, word,
+character, byte, maximum line length, blank line.
+  -b, --bytes            print the byte counts
+  -c, --chars            print the character counts
+  -l, --lines            print the newline counts
+  -w, --words            print the word counts
+  -L, --max-line-length  print the maximum display width, East Asian wide
+                           characters take two columns
+      --blank-lines      print the counts of lines without any word
+      --top=N            print the N most frequent words of all the files
+                           after the counts
+  -i, --ignore-case      fold the words to lower case for --top
+  -j, --jobs=N           count with N workers, large files are split in
+                           byte ranges counted in parallel
+  -r, --recursive        count the files found in the directories
+      --include=GLOB     only count the files of the directories whose
+                           name matches GLOB, can be repeated
+      --exclude=PATTERN  skip the files and directories matching the
+                           .gitignore style PATTERN, can be repeated
+      --exclude-from=F   read the exclude patterns from F, like .gitignore
+      --by-ext           print a summary by file extension instead of a
+                           row per file
+  -f, --follow           keep the files open and print their updated counts
+                           as they grow, like tail -f
+      --interval=DURATION  time between two updates with --follow, like
+                           500ms or 2s (default 1s)
+      --encoding=ENC     read the input as utf-8 (default), utf-16le,
+                           utf-16be, latin1 or auto, which detects the
+                           byte order mark; invalid byte sequences are
+                           reported on stderr
+      --format=FORMAT    print the counts as table (default), json or csv
+      --files0-from=F    read input from the files specified by
+                           NUL-terminated names in file F;
+                           If F is - then read names from standard input
+      --help             display this help and exit
+`
+
+var errHelp = errors.New("help requested")
+
+type options struct {
+	bytes   bool
+	lines   bool
+	words   bool
+	chars   bool
+	maxLine bool
+	blank   bool
+
+	top        int  // most frequent words printed, 0 for none
+	ignoreCase bool // fold the case of the words for top
+
+	files0From string
+	jobs       int
+	format     string
+
+	encoding      wc.Encoding
+	checkEncoding bool // report the invalid byte sequences
+
+	recursive bool
+	include   []string
+	exclude   ignoreList
+	byExt     bool
+
+	follow   bool
+	interval time.Duration
+}
+
+// columns returns how many counters will be printed per row
+func (o options) columns() int {
+	return len(o.fields())
+}
+
+// fields returns the names of the selected counters, in print order
+func (o options) fields() []string {
+	var names []string
+
+	if o.lines {
+		names = append(names, "lines")
+	}
+	if o.words {
+		names = append(names, "words")
+	}
+	if o.chars {
+		names = append(names, "chars")
+	}
+	if o.bytes {
+		names = append(names, "bytes")
+	}
+	if o.maxLine {
+		names = append(names, "max_line_length")
+	}
+	if o.blank {
+		names = append(names, "blank_lines")
+	}
+
+	return names
+}
+
+// values returns the selected counters of c, in print order
+func (o options) values(c *wc.Counts) []int64 {
+	var values []int64
+
+	if o.lines {
+		values = append(values, c.Lines)
+	}
+	if o.words {
+		values = append(values, c.Words)
+	}
+	if o.chars {
+		values = append(values, c.Chars)
+	}
+	if o.bytes {
+		values = append(values, c.Bytes)
+	}
+	if o.maxLine {
+		values = append(values, c.MaxLineLength)
+	}
+	if o.blank {
+		values = append(values, c.BlankLines)
+	}
+
+	return values
+}
+
+// set assigns the value of an option that takes an argument
+func (o *options) set(name, value string) error {
+	switch name {
+	case "files0-from":
+		o.files0From = value
+
+	case "jobs":
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return fmt.Errorf("invalid number of jobs: '%s'", value)
+		}
+		o.jobs = n
+
+	case "top":
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return fmt.Errorf("invalid number of words: '%s'", value)
+		}
+		o.top = n
+
+	case "encoding":
+		enc, err := wc.ParseEncoding(value)
+		if err != nil {
+			return fmt.Errorf("invalid encoding: '%s', valid encodings are utf-8, utf-16le, utf-16be, latin1 and auto", value)
+		}
+		o.encoding = enc
+		o.checkEncoding = true
+
+	case "include":
+		if _, err := path.Match(value, ""); err != nil {
+			return fmt.Errorf("invalid include glob: '%s'", value)
+		}
+		o.include = append(o.include, value)
+
+	case "exclude":
+		if rule, ok := parseIgnore(value); ok {
+			o.exclude = append(o.exclude, rule)
+		}
+
+	case "exclude-from":
+		list, err := readIgnoreFile(value)
+		if err != nil {
+			return fmt.Errorf("cannot read exclude patterns: %w", err)
+		}
+		o.exclude = append(o.exclude, list...)
+
+	case "interval":
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			// plain seconds, like tail -s
+			var secs float64
+			secs, err = strconv.ParseFloat(value, 64)
+			d = time.Duration(secs * float64(time.Second))
+		}
+		if err != nil || d <= 0 {
+			return fmt.Errorf("invalid interval: '%s'", value)
+		}
+		o.interval = d
+
+	case "format":
+		if value != "table" && value != "json" && value != "csv" {
+			return fmt.Errorf("invalid format: '%s', valid formats are table, json and csv", value)
+		}
+		o.format = value
+	}
+
+	return nil
+}
+
+// splittable reports whether a file can be counted as independent byte
+// ranges: the line length and the blank lines need the line where each range
+// starts, the words need their first letters, the invalid sequences are
+// reported in order and only UTF-8 can be split
+func (o options) splittable() bool {
+	return !o.maxLine && !o.blank && o.top == 0 && !o.checkEncoding
+}
+
+// parseArgs parses the command line the way getopt_long does: short flags
+// can be combined (-lw), long options take their value after '=' or as the
+// next argument, options and files can be mixed and "--" ends the options
+func parseArgs(args []string) (options, []string, error) {
+	var (
+		opts  = options{jobs: 1, interval: time.Second}
+		files []string
+	)
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		switch {
+		case arg == "--":
+			files = append(files, args[i+1:]...)
+			i = len(args)
+
+		case strings.HasPrefix(arg, "--"):
+			name, value, hasValue := strings.Cut(arg[2:], "=")
+
+			switch name {
+			case "bytes":
+				opts.bytes = true
+			case "chars":
+				opts.chars = true
+			case "lines":
+				opts.lines = true
+			case "words":
+				opts.words = true
+			case "max-line-length":
+				opts.maxLine = true
+			case "blank-lines":
+				opts.blank = true
+			case "ignore-case":
+				opts.ignoreCase = true
+			case "help":
+				return opts, nil, errHelp
+
+			case "recursive":
+				opts.recursive = true
+			case "by-ext":
+				opts.byExt = true
+			case "follow":
+				opts.follow = true
+
+			case "files0-from", "jobs", "top", "format", "encoding", "include", "exclude", "exclude-from", "interval":
+				if !hasValue {
+					if i+1 == len(args) {
+						return opts, nil, fmt.Errorf("option '--%s' requires an argument", name)
+					}
+					i++
+					value = args[i]
+				}
+
+				if err := opts.set(name, value); err != nil {
+					return opts, nil, err
+				}
+				continue
+
+			default:
+				return opts, nil, fmt.Errorf("unrecognized option '%s'", arg)
+			}
+
+			if hasValue {
+				return opts, nil, fmt.Errorf("option '--%s' doesn't allow an argument", name)
+			}
+
+		case strings.HasPrefix(arg, "-") && arg != "-":
+		SHORT:
+			for j, flag := range arg[1:] {
+				switch flag {
+				case 'b':
+					opts.bytes = true
+				case 'c':
+					opts.chars = true
+				case 'l':
+					opts.lines = true
+				case 'w':
+					opts.words = true
+				case 'L':
+					opts.maxLine = true
+				case 'i':
+					opts.ignoreCase = true
+				case 'r':
+					opts.recursive = true
+				case 'f':
+					opts.follow = true
+
+				case 'j':
+					// the value is the rest of the argument or the next one
+					value := arg[j+2:]
+					if value == "" {
+						if i+1 == len(args) {
+							return opts, nil, fmt.Errorf("option requires an argument -- '%c'", flag)
+						}
+						i++
+						value = args[i]
+					}
+
+					if err := opts.set("jobs", value); err != nil {
+						return opts, nil, err
+					}
+					break SHORT
+
+				default:
+					return opts, nil, fmt.Errorf("invalid option -- '%c'", flag)
+				}
+			}
+
+		default:
+			files = append(files, arg)
+		}
+	}
+
+	if opts.columns() == 0 {
+		opts.lines = true
+		opts.words = true
+		opts.bytes = true
+	}
+
+	if opts.follow && opts.byExt {
+		return opts, nil, fmt.Errorf("--by-ext can not be used with --follow")
+	}
+
+	if opts.top > 0 && opts.follow {
+		return opts, nil, fmt.Errorf("--top can not be used with --follow")
+	}
+	if opts.top > 0 && opts.format == "csv" {
+		return opts, nil, fmt.Errorf("--top can not be used with --format=csv")
+	}
+
+	if opts.files0From != "" && len(files) > 0 {
+		return opts, nil, fmt.Errorf("extra operand '%s'\nfile operands cannot be combined with --files0-from", files[0])
+	}
+
+	return opts, files, nil
+}
+
+// readFiles0 reads the NUL separated list of file names in file, "-" being
+// the standard input
+func readFiles0(file string) ([]string, error) {
+	var r io.Reader = os.Stdin
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, fmt.Errorf("cannot open '%s' for reading: %w", file, err)
+		}
+		defer f.Close()
+
+		r = f
+	}
+
+	var files []string
+
+	scanner := bufio.NewScanner(r)
+	scanner.Split(scanNul)
+	for scanner.Scan() {
+		name := scanner.Text()
+
+		switch {
+		case name == "":
+			return nil, fmt.Errorf("%s: invalid zero-length file name", file)
+		case name == "-" && file == "-":
+			return nil, fmt.Errorf("when reading file names from stdin, no file name of '-' allowed")
+		}
+
+		files = append(files, name)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+// scanNul is a bufio.SplitFunc for NUL terminated tokens, the last one may
+// be unterminated
+func scanNul(data []byte, atEOF bool) (int, []byte, error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+
+	if i := bytes.IndexByte(data, 0); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+
+	if atEOF {
+		return len(data), data, nil
+	}
+
+	return 0, nil, nil
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseArgs(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      []string
+		want      options
+		wantFiles []string
+		wantErr   string
+	}{
+		{
+			name:      "default counters",
+			args:      []string{"a.txt"},
+			want:      options{lines: true, words: true, bytes: true, jobs: 1, interval: time.Second},
+			wantFiles: []string{"a.txt"},
+		},
+		{
+			name:      "combined short flags",
+			args:      []string{"-lw", "a.txt", "-cL"},
+			want:      options{lines: true, words: true, chars: true, maxLine: true, jobs: 1, interval: time.Second},
+			wantFiles: []string{"a.txt"},
+		},
+		{
+			name: "short flag value in the argument",
+			args: []string{"-lj4"},
+			want: options{lines: true, jobs: 4, interval: time.Second},
+		},
+		{
+			name: "short flag value in the next argument",
+			args: []string{"-j", "4", "-b"},
+			want: options{bytes: true, jobs: 4, interval: time.Second},
+		},
+		{
+			name: "long options",
+			args: []string{"--lines", "--blank-lines", "--top=3", "--ignore-case", "--format", "json"},
+			want: options{lines: true, blank: true, top: 3, ignoreCase: true, format: "json", jobs: 1, interval: time.Second},
+		},
+		{
+			name: "long option value in the next argument",
+			args: []string{"--jobs", "2", "-c", "--interval", "0.5"},
+			want: options{chars: true, jobs: 2, interval: 500 * time.Millisecond},
+		},
+		{
+			name:      "standard input",
+			args:      []string{"-l", "-"},
+			want:      options{lines: true, jobs: 1, interval: time.Second},
+			wantFiles: []string{"-"},
+		},
+		{
+			name:      "end of options",
+			args:      []string{"-w", "--", "-l", "--bytes"},
+			want:      options{words: true, jobs: 1, interval: time.Second},
+			wantFiles: []string{"-l", "--bytes"},
+		},
+		{
+			name: "files0-from",
+			args: []string{"--files0-from=names", "-l"},
+			want: options{lines: true, files0From: "names", jobs: 1, interval: time.Second},
+		},
+		{
+			name:    "help",
+			args:    []string{"-l", "--help", "a.txt"},
+			wantErr: errHelp.Error(),
+		},
+		{name: "invalid short flag", args: []string{"-lx"}, wantErr: "invalid option -- 'x'"},
+		{name: "missing short flag value", args: []string{"-j"}, wantErr: "option requires an argument -- 'j'"},
+		{name: "unrecognized option", args: []string{"--line"}, wantErr: "unrecognized option '--line'"},
+		{name: "missing long option value", args: []string{"--top"}, wantErr: "option '--top' requires an argument"},
+		{name: "unexpected long option value", args: []string{"--lines=2"}, wantErr: "option '--lines' doesn't allow an argument"},
+		{name: "invalid jobs", args: []string{"-j0"}, wantErr: "invalid number of jobs: '0'"},
+		{name: "invalid format", args: []string{"--format=xml"}, wantErr: "invalid format: 'xml', valid formats are table, json and csv"},
+		{name: "invalid interval", args: []string{"--interval=-1s"}, wantErr: "invalid interval: '-1s'"},
+		{name: "by-ext with follow", args: []string{"--by-ext", "-f"}, wantErr: "--by-ext can not be used with --follow"},
+		{name: "top with csv", args: []string{"--top=1", "--format=csv"}, wantErr: "--top can not be used with --format=csv"},
+		{
+			name:    "files0-from with files",
+			args:    []string{"--files0-from", "names", "a.txt"},
+			wantErr: "extra operand 'a.txt'\nfile operands cannot be combined with --files0-from",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, files, err := parseArgs(tt.args)
+
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("parseArgs() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseArgs() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseArgs() = %+v, want %+v", got, tt.want)
+			}
+			if !reflect.DeepEqual(files, tt.wantFiles) {
+				t.Errorf("parseArgs() files = %q, want %q", files, tt.wantFiles)
+			}
+		})
+	}
+}
+
+func TestReadFiles0(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    []string
+		wantErr string
+	}{
+		{name: "terminated names", data: "a.txt\x00b c.txt\x00", want: []string{"a.txt", "b c.txt"}},
+		{name: "unterminated last name", data: "a.txt\x00dir/b.txt", want: []string{"a.txt", "dir/b.txt"}},
+		{name: "newlines are part of the names", data: "a\nb\x00", want: []string{"a\nb"}},
+		{name: "no names", data: ""},
+		{name: "empty name", data: "a.txt\x00\x00b.txt", wantErr: ": invalid zero-length file name"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := writeFile(t, "names", []byte(tt.data))
+
+			got, err := readFiles0(file)
+
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != file+tt.wantErr {
+					t.Fatalf("readFiles0() error = %v, want %v", err, file+tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readFiles0() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("readFiles0() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("stdin", func(t *testing.T) {
+		stdin, err := os.Open(writeFile(t, "names", []byte("a.txt\x00-\x00")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer stdin.Close()
+
+		saved := os.Stdin
+		os.Stdin = stdin
+		defer func() { os.Stdin = saved }()
+
+		want := "when reading file names from stdin, no file name of '-' allowed"
+		if _, err := readFiles0("-"); err == nil || err.Error() != want {
+			t.Errorf("readFiles0() error = %v, want %v", err, want)
+		}
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		if _, err := readFiles0("missing"); err == nil {
+			t.Error("readFiles0() error = nil, want an error")
+		}
+	})
+}
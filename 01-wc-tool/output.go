@@ -0,0 +1,327 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+	"wc/wc"
+)
+
+// printer writes the rows of the counted files
+type printer interface {
+	// row prints the counters of a file, counts is nil when the file could
+	// not be read at all
+	row(file string, counts *wc.Counts, err error)
+
+	// group prints the counters of the files with the extension ext, it
+	// replaces row in the summary by extension
+	group(ext string, files int64, counts wc.Counts)
+
+	// total prints the totals of the n files once all the rows are printed,
+	// followed by the most frequent words when --top is given
+	total(counts wc.Counts, n int, words []wc.WordCount) error
+}
+
+func newPrinter(w io.Writer, width int, opts options) printer {
+	switch opts.format {
+	case "json":
+		return &jsonPrinter{w: w, opts: opts}
+
+	case "csv":
+		return &csvPrinter{w: csv.NewWriter(w), opts: opts}
+	}
+
+	return &tablePrinter{w: w, width: width, opts: opts}
+}
+
+// tablePrinter prints the rows like GNU wc, errors go to stderr
+type tablePrinter struct {
+	w     io.Writer
+	width int
+	opts  options
+}
+
+func (p *tablePrinter) row(file string, counts *wc.Counts, err error) {
+	if err != nil {
+		printError(file, err)
+	}
+	if counts == nil {
+		return
+	}
+
+	var row []string
+	for _, v := range p.opts.values(counts) {
+		row = append(row, fmt.Sprintf("%*d", p.width, v))
+	}
+
+	if file != "" {
+		row = append(row, file)
+	}
+
+	fmt.Fprintln(p.w, strings.Join(row, " "))
+}
+
+func (p *tablePrinter) group(ext string, files int64, counts wc.Counts) {
+	unit := "files"
+	if files == 1 {
+		unit = "file"
+	}
+
+	p.row(fmt.Sprintf("%s (%d %s)", ext, files, unit), &counts, nil)
+}
+
+func (p *tablePrinter) total(counts wc.Counts, n int, words []wc.WordCount) error {
+	if n > 1 {
+		p.row("total", &counts, nil)
+	}
+
+	if len(words) > 0 {
+		fmt.Fprintln(p.w)
+	}
+	for _, w := range words {
+		fmt.Fprintf(p.w, "%*d %s\n", p.width, w.Count, w.Word)
+	}
+
+	return nil
+}
+
+// record is a row of the machine readable formats, a counter that was not
+// selected is left out
+type record struct {
+	File          string `json:"file,omitempty"`
+	Ext           string `json:"ext,omitempty"`
+	Files         *int64 `json:"files,omitempty"`
+	Lines         *int64 `json:"lines,omitempty"`
+	Words         *int64 `json:"words,omitempty"`
+	Chars         *int64 `json:"chars,omitempty"`
+	Bytes         *int64 `json:"bytes,omitempty"`
+	MaxLineLength *int64 `json:"max_line_length,omitempty"`
+	BlankLines    *int64 `json:"blank_lines,omitempty"`
+	Error         string `json:"error,omitempty"`
+}
+
+// wordRecord is a word of the "top_words" list
+type wordRecord struct {
+	Word  string `json:"word"`
+	Count int64  `json:"count"`
+}
+
+func newRecord(file string, counts *wc.Counts, err error, opts options) record {
+	r := record{File: displayName(file)}
+	if err != nil {
+		r.Error = errorText(err)
+	}
+	if counts == nil {
+		return r
+	}
+
+	if opts.lines {
+		r.Lines = &counts.Lines
+	}
+	if opts.words {
+		r.Words = &counts.Words
+	}
+	if opts.chars {
+		r.Chars = &counts.Chars
+	}
+	if opts.bytes {
+		r.Bytes = &counts.Bytes
+	}
+	if opts.maxLine {
+		r.MaxLineLength = &counts.MaxLineLength
+	}
+	if opts.blank {
+		r.BlankLines = &counts.BlankLines
+	}
+
+	return r
+}
+
+// jsonPrinter prints a single object, {"files": [...], "total": {...}},
+// written as the files are counted, or {"extensions": [...], "total": {...}}
+// for the summary by extension. The most frequent words follow the total,
+// as "top_words": [{"word": ..., "count": ...}]. With --follow every row is
+// an object on its own line.
+type jsonPrinter struct {
+	w    io.Writer
+	opts options
+	n    int
+	err  error
+}
+
+func (p *jsonPrinter) row(file string, counts *wc.Counts, err error) {
+	if p.opts.follow {
+		p.write("", newRecord(file, counts, err, p.opts))
+		if p.err == nil {
+			_, p.err = io.WriteString(p.w, "\n")
+		}
+		return
+	}
+
+	p.write(p.separator("files"), newRecord(file, counts, err, p.opts))
+}
+
+func (p *jsonPrinter) group(ext string, files int64, counts wc.Counts) {
+	r := newRecord("", &counts, nil, p.opts)
+	r.File = ""
+	r.Ext = ext
+	r.Files = &files
+
+	p.write(p.separator("extensions"), r)
+}
+
+// separator returns what comes before the next record, the opening of the
+// object and of the list named key for the first one
+func (p *jsonPrinter) separator(key string) string {
+	p.n++
+	if p.n == 1 {
+		return `{"` + key + `":[`
+	}
+
+	return ","
+}
+
+func (p *jsonPrinter) total(counts wc.Counts, n int, words []wc.WordCount) error {
+	r := newRecord("total", &counts, nil, p.opts)
+	r.File = ""
+	if p.opts.byExt {
+		files := int64(n)
+		r.Files = &files
+	}
+
+	// the list is still to be opened when no file could be counted
+	key := "files"
+	if p.opts.byExt {
+		key = "extensions"
+	}
+	if p.n == 0 {
+		p.write(`{"`+key+`":[],"total":`, r)
+	} else {
+		p.write(`],"total":`, r)
+	}
+
+	if words != nil {
+		top := make([]wordRecord, len(words))
+		for i, w := range words {
+			top[i] = wordRecord{w.Word, w.Count}
+		}
+		p.write(`,"top_words":`, top)
+	}
+
+	if p.err == nil {
+		_, p.err = io.WriteString(p.w, "}\n")
+	}
+
+	return p.err
+}
+
+func (p *jsonPrinter) write(prefix string, v any) {
+	if p.err != nil {
+		return
+	}
+
+	buf, err := json.Marshal(v)
+	if err != nil {
+		p.err = err
+		return
+	}
+
+	_, p.err = io.WriteString(p.w, prefix+string(buf))
+}
+
+// csvPrinter prints a header, one line per file and a last line for the
+// total, named "total". The summary by extension has an ext and a files
+// column instead of the file and error ones.
+type csvPrinter struct {
+	w      *csv.Writer
+	opts   options
+	header bool
+}
+
+func (p *csvPrinter) row(file string, counts *wc.Counts, err error) {
+	p.writeHeader()
+
+	line := []string{displayName(file)}
+	if counts != nil {
+		for _, v := range p.opts.values(counts) {
+			line = append(line, strconv.FormatInt(v, 10))
+		}
+	} else {
+		line = append(line, make([]string, len(p.opts.fields()))...)
+	}
+
+	msg := ""
+	if err != nil {
+		msg = errorText(err)
+	}
+
+	p.w.Write(append(line, msg))
+	if p.opts.follow {
+		p.w.Flush()
+	}
+}
+
+func (p *csvPrinter) group(ext string, files int64, counts wc.Counts) {
+	p.writeHeader()
+
+	line := []string{ext, strconv.FormatInt(files, 10)}
+	for _, v := range p.opts.values(&counts) {
+		line = append(line, strconv.FormatInt(v, 10))
+	}
+
+	p.w.Write(line)
+}
+
+func (p *csvPrinter) writeHeader() {
+	if p.header {
+		return
+	}
+	p.header = true
+
+	if p.opts.byExt {
+		p.w.Write(append([]string{"ext", "files"}, p.opts.fields()...))
+	} else {
+		p.w.Write(append(append([]string{"file"}, p.opts.fields()...), "error"))
+	}
+}
+
+func (p *csvPrinter) total(counts wc.Counts, n int, _ []wc.WordCount) error {
+	if p.opts.byExt {
+		p.group("total", int64(n), counts)
+	} else {
+		p.row("total", &counts, nil)
+	}
+	p.w.Flush()
+
+	return p.w.Error()
+}
+
+func printError(file string, err error) {
+	fmt.Fprintf(os.Stderr, "%s: %s: %s\n", name, displayName(file), errorText(err))
+}
+
+// displayName returns the name of file in messages, "-" for the standard input
+func displayName(file string) string {
+	if file == "" {
+		return "-"
+	}
+
+	return file
+}
+
+// errorText returns the error without the operation and path already
+// given by the file name
+func errorText(err error) string {
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		err = pathErr.Err
+	}
+
+	return err.Error()
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+	"wc/wc"
+)
+
+// printed is what a printer is given: the rows or the groups, then the total
+type printed struct {
+	rows   []printedRow
+	groups []printedGroup
+	total  wc.Counts
+	n      int
+	words  []wc.WordCount
+}
+
+type printedRow struct {
+	file   string
+	counts *wc.Counts
+	err    error
+}
+
+type printedGroup struct {
+	ext    string
+	files  int64
+	counts wc.Counts
+}
+
+func (in printed) print(t *testing.T, args ...string) string {
+	t.Helper()
+
+	opts, _, err := parseArgs(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var sb strings.Builder
+	p := newPrinter(&sb, 3, opts)
+	for _, r := range in.rows {
+		p.row(r.file, r.counts, r.err)
+	}
+	for _, g := range in.groups {
+		p.group(g.ext, g.files, g.counts)
+	}
+	if err := p.total(in.total, in.n, in.words); err != nil {
+		t.Fatal(err)
+	}
+
+	return sb.String()
+}
+
+var (
+	countsA   = wc.Counts{Lines: 1, Words: 2, Chars: 10, Bytes: 12}
+	countsB   = wc.Counts{Lines: 3, Words: 4, Chars: 20, Bytes: 20}
+	countsAB  = wc.Counts{Lines: 4, Words: 6, Chars: 30, Bytes: 32}
+	errAbsent = &fs.PathError{Op: "open", Path: "absent", Err: fs.ErrNotExist}
+)
+
+func TestJSONPrinter(t *testing.T) {
+	tests := []struct {
+		name string
+		in   printed
+		args []string
+		want string
+	}{
+		{
+			name: "files",
+			in: printed{
+				rows:  []printedRow{{"a.txt", &countsA, nil}, {"absent", nil, errAbsent}, {"", &countsB, nil}},
+				total: countsAB,
+				n:     3,
+			},
+			want: `{"files":[{"file":"a.txt","lines":1,"words":2,"bytes":12},{"file":"absent","error":"file does not exist"},{"file":"-","lines":3,"words":4,"bytes":20}],"total":{"lines":4,"words":6,"bytes":32}}` + "\n",
+		},
+		{
+			name: "selected counters",
+			in:   printed{rows: []printedRow{{"a.txt", &countsA, nil}}, total: countsA, n: 1},
+			args: []string{"-cl"},
+			want: `{"files":[{"file":"a.txt","lines":1,"chars":10}],"total":{"lines":1,"chars":10}}` + "\n",
+		},
+		{
+			name: "top words",
+			in: printed{
+				rows:  []printedRow{{"a.txt", &countsA, nil}},
+				total: countsA,
+				n:     1,
+				words: []wc.WordCount{{Word: "the", Count: 5}, {Word: "a", Count: 2}},
+			},
+			args: []string{"--top=2"},
+			want: `{"files":[{"file":"a.txt","lines":1,"words":2,"bytes":12}],"total":{"lines":1,"words":2,"bytes":12},"top_words":[{"word":"the","count":5},{"word":"a","count":2}]}` + "\n",
+		},
+		{
+			name: "by extension",
+			in: printed{
+				groups: []printedGroup{{".go", 1, countsA}, {".txt", 2, countsB}},
+				total:  countsAB,
+				n:      3,
+			},
+			args: []string{"--by-ext"},
+			want: `{"extensions":[{"ext":".go","files":1,"lines":1,"words":2,"bytes":12},{"ext":".txt","files":2,"lines":3,"words":4,"bytes":20}],"total":{"files":3,"lines":4,"words":6,"bytes":32}}` + "\n",
+		},
+		{
+			name: "by extension without any counted file",
+			in:   printed{},
+			args: []string{"--by-ext"},
+			want: `{"extensions":[],"total":{"files":0,"lines":0,"words":0,"bytes":0}}` + "\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.in.print(t, append(tt.args, "--format=json")...)
+			if got != tt.want {
+				t.Errorf("output = %s, want %s", got, tt.want)
+			}
+			if !json.Valid([]byte(got)) {
+				t.Errorf("output is not valid JSON: %s", got)
+			}
+		})
+	}
+}
+
+func TestCSVPrinter(t *testing.T) {
+	tests := []struct {
+		name string
+		in   printed
+		args []string
+		want string
+	}{
+		{
+			name: "files",
+			in: printed{
+				rows:  []printedRow{{"a,b.txt", &countsA, nil}, {"absent", nil, errAbsent}},
+				total: countsA,
+				n:     2,
+			},
+			want: "file,lines,words,bytes,error\n" +
+				"\"a,b.txt\",1,2,12,\n" +
+				"absent,,,,file does not exist\n" +
+				"total,1,2,12,\n",
+		},
+		{
+			name: "by extension",
+			in: printed{
+				groups: []printedGroup{{".go", 1, countsA}, {".txt", 2, countsB}},
+				total:  countsAB,
+				n:      3,
+			},
+			args: []string{"--by-ext", "-l"},
+			want: "ext,files,lines\n.go,1,1\n.txt,2,3\ntotal,3,4\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.in.print(t, append(tt.args, "--format=csv")...); got != tt.want {
+				t.Errorf("output = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTablePrinter(t *testing.T) {
+	tests := []struct {
+		name string
+		in   printed
+		args []string
+		want string
+	}{
+		{
+			name: "single file",
+			in:   printed{rows: []printedRow{{"a.txt", &countsA, nil}}, total: countsA, n: 1},
+			want: "  1   2  12 a.txt\n",
+		},
+		{
+			name: "total",
+			in: printed{
+				rows:  []printedRow{{"a.txt", &countsA, nil}, {"", &countsB, nil}},
+				total: countsAB,
+				n:     2,
+			},
+			want: "  1   2  12 a.txt\n  3   4  20\n  4   6  32 total\n",
+		},
+		{
+			name: "by extension",
+			in: printed{
+				groups: []printedGroup{{".go", 1, countsA}, {".txt", 2, countsB}},
+				total:  countsAB,
+				n:      3,
+			},
+			args: []string{"--by-ext", "-w"},
+			want: "  2 .go (1 file)\n  4 .txt (2 files)\n  6 total\n",
+		},
+		{
+			name: "top words",
+			in: printed{
+				rows:  []printedRow{{"a.txt", &countsA, nil}},
+				total: countsA,
+				n:     1,
+				words: []wc.WordCount{{Word: "the", Count: 5}},
+			},
+			args: []string{"-l", "--top=1"},
+			want: "  1 a.txt\n\n  5 the\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.in.print(t, tt.args...); got != tt.want {
+				t.Errorf("output = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestErrorText(t *testing.T) {
+	if got := errorText(errAbsent); got != "file does not exist" {
+		t.Errorf("errorText() = %q, want %q", got, "file does not exist")
+	}
+	if got := errorText(errors.New("read failed")); got != "read failed" {
+		t.Errorf("errorText() = %q, want %q", got, "read failed")
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"io"
+	"os"
+	"sync"
+	"wc/wc"
+)
+
+// minRangeSize is the smallest byte range a file is split into when counted
+// by several workers, smaller files are counted by a single worker
+const minRangeSize = 16 * 1024 * 1024
+
+type result struct {
+	counter *wc.Counter
+	err     error
+}
+
+// countAll counts files with opts.jobs workers. The result of each file is
+// delivered on the channel at the same index, so the caller can print them
+// in order while the following files are still being counted.
+func countAll(files []string, opts options) []chan result {
+	results := make([]chan result, len(files))
+	for i := range results {
+		results[i] = make(chan result, 1)
+	}
+
+	tasks := make(chan func())
+	for i := 0; i < opts.jobs; i++ {
+		go func() {
+			for task := range tasks {
+				task()
+			}
+		}()
+	}
+
+	go func() {
+		defer close(tasks)
+
+		for i, file := range files {
+			res := results[i]
+
+			if opts.jobs > 1 && opts.splittable() {
+				if size, ok := splitSize(file); ok {
+					countRanges(file, size, opts.jobs, tasks, res)
+					continue
+				}
+			}
+
+			file := file
+			tasks <- func() {
+				counts, err := countFile(file, opts)
+				res <- result{counts, err}
+			}
+		}
+	}()
+
+	return results
+}
+
+// splitSize returns the size of file when it is a regular file big enough
+// to be split in byte ranges. The standard input is never split, it may be
+// read from an offset other than 0.
+func splitSize(file string) (int64, bool) {
+	if file == "" || file == "-" {
+		return 0, false
+	}
+
+	fi, err := stat(file)
+	if err != nil || !fi.Mode().IsRegular() || fi.Size() < 2*minRangeSize {
+		return 0, false
+	}
+
+	return fi.Size(), true
+}
+
+// countRanges splits file in up to jobs byte ranges, queues one task per
+// range and joins their counters, in file order, once all of them are done
+func countRanges(file string, size int64, jobs int, tasks chan<- func(), res chan<- result) {
+	f, err := os.Open(file)
+	if err != nil {
+		res <- result{nil, err}
+		return
+	}
+
+	bounds, err := splitRanges(f, size, jobs)
+	if err != nil {
+		f.Close()
+		res <- result{nil, err}
+		return
+	}
+
+	var wg sync.WaitGroup
+	parts := make([]result, len(bounds)-1)
+
+	for i := range parts {
+		i := i
+		start, end := bounds[i], bounds[i+1]
+
+		wg.Add(1)
+		tasks <- func() {
+			defer wg.Done()
+
+			c := wc.NewCounter(nil)
+			_, err := c.ReadFrom(io.NewSectionReader(f, start, end-start))
+			parts[i] = result{c, err}
+		}
+	}
+
+	go func() {
+		wg.Wait()
+		f.Close()
+
+		total := wc.NewCounter(nil)
+		for _, part := range parts {
+			if part.err != nil {
+				res <- result{total, part.err}
+				return
+			}
+			total.Join(part.counter)
+		}
+
+		res <- result{total, nil}
+	}()
+}
+
+// splitRanges returns the offsets that split f in up to n ranges of at
+// least minRangeSize bytes. Every offset but the last one is moved forward
+// to the start of a rune, so no range begins with UTF-8 continuation bytes.
+func splitRanges(f *os.File, size int64, n int) ([]int64, error) {
+	n = min(n, int(size/minRangeSize))
+	bounds := []int64{0}
+
+	buf := make([]byte, 64)
+	for i := 1; i < n; i++ {
+		off := max(size*int64(i)/int64(n), bounds[len(bounds)-1])
+
+	ALIGN:
+		for off < size {
+			m, err := f.ReadAt(buf, off)
+			if err != nil && err != io.EOF {
+				return nil, err
+			}
+
+			for _, b := range buf[:m] {
+				if b&0xC0 != 0x80 {
+					break ALIGN
+				}
+				off++
+			}
+
+			if m == 0 {
+				break
+			}
+		}
+
+		if off < size && off > bounds[len(bounds)-1] {
+			bounds = append(bounds, off)
+		}
+	}
+
+	return append(bounds, size), nil
+}
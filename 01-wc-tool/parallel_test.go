@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"wc/wc"
+)
+
+// bigText returns text of at least size bytes, with multi-byte runes
+func bigText(size int) []byte {
+	line := []byte("héllo wörld, ünïcode ☃ text\n")
+
+	return bytes.Repeat(line, size/len(line)+1)
+}
+
+func TestSplitSize(t *testing.T) {
+	small := writeFile(t, "small.txt", []byte("hello\n"))
+	big := writeFile(t, "big.txt", nil)
+	if err := os.Truncate(big, 2*minRangeSize); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		file   string
+		want   int64
+		wantOk bool
+	}{
+		{name: "big file", file: big, want: 2 * minRangeSize, wantOk: true},
+		{name: "small file", file: small},
+		{name: "directory", file: t.TempDir()},
+		{name: "missing file", file: filepath.Join(t.TempDir(), "missing")},
+		{name: "stdin", file: "-"},
+		{name: "stdin without a name", file: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := splitSize(tt.file)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("splitSize() = %d, %v, want %d, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestSplitRanges(t *testing.T) {
+	const size = 3 * minRangeSize
+
+	// "é" and "☃" straddle the offsets of 2 and 3 ranges
+	data := make([]byte, size)
+	copy(data[minRangeSize*3/2-1:], "é")
+	copy(data[minRangeSize-1:], "☃")
+	copy(data[2*minRangeSize:], "\x80\x80")
+	file := writeFile(t, "ranges.bin", data)
+
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	tests := []struct {
+		name string
+		n    int
+		want []int64
+	}{
+		{name: "one range", n: 1, want: []int64{0, size}},
+		{name: "two ranges", n: 2, want: []int64{0, minRangeSize*3/2 + 1, size}},
+		{name: "three ranges", n: 3, want: []int64{0, minRangeSize + 2, 2*minRangeSize + 2, size}},
+		{name: "no more ranges than minRangeSize allows", n: 8, want: []int64{0, minRangeSize + 2, 2*minRangeSize + 2, size}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := splitRanges(f, size, tt.n)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitRanges() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCountAll(t *testing.T) {
+	data := bigText(2*minRangeSize + 1000)
+	file := writeFile(t, "big.txt", data)
+
+	c := wc.NewCounter(nil)
+	c.Write(data)
+	want := c.Counts()
+
+	opts, _, err := parseArgs([]string{"-j", "4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stdin, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stdin.Close()
+
+	// stdin is a regular file big enough to be split
+	saved := os.Stdin
+	os.Stdin = stdin
+	defer func() { os.Stdin = saved }()
+
+	files := []string{file, "-"}
+	for i, res := range countAll(files, opts) {
+		got := <-res
+		if got.err != nil {
+			t.Fatalf("countAll() %s error = %v", files[i], got.err)
+		}
+		// the split counters do not track the line length, -L is never split
+		counts := got.counter.Counts()
+		if got, want := opts.values(&counts), opts.values(&want); !reflect.DeepEqual(got, want) {
+			t.Errorf("countAll() %s = %v, want %v", files[i], got, want)
+		}
+	}
+}
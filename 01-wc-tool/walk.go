@@ -0,0 +1,99 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// expandFiles replaces the directories in files by the files found walking
+// them, in lexical order, keeping the ones matching the include globs and
+// skipping the excluded ones and the .git directories. A directory that can
+// not be read is reported and expandFiles returns false.
+func expandFiles(files []string, opts options) ([]string, bool) {
+	var expanded []string
+	ok := true
+
+	for _, file := range files {
+		fi, err := os.Stat(file)
+		if file == "-" || err != nil || !fi.IsDir() {
+			// left to countFile, which reports the error
+			expanded = append(expanded, file)
+			continue
+		}
+
+		err = filepath.WalkDir(file, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				printError(p, err)
+				ok = false
+				return nil
+			}
+
+			rel, _ := filepath.Rel(file, p)
+			rel = filepath.ToSlash(rel)
+
+			if d.IsDir() {
+				if rel != "." && (d.Name() == ".git" || opts.exclude.excluded(rel, true)) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			if !isRegularEntry(p, d) || opts.exclude.excluded(rel, false) || !opts.included(rel) {
+				return nil
+			}
+
+			expanded = append(expanded, p)
+			return nil
+		})
+		if err != nil {
+			printError(file, err)
+			ok = false
+		}
+	}
+
+	return expanded, ok
+}
+
+// isRegularEntry reports whether the entry is a regular file or a symbolic
+// link to one
+func isRegularEntry(p string, d fs.DirEntry) bool {
+	if d.Type().IsRegular() {
+		return true
+	}
+	if d.Type()&fs.ModeSymlink == 0 {
+		return false
+	}
+
+	fi, err := os.Stat(p)
+
+	return err == nil && fi.Mode().IsRegular()
+}
+
+// included reports whether the base name of rel matches one of the include
+// globs, every file is included when there is none
+func (o options) included(rel string) bool {
+	if len(o.include) == 0 {
+		return true
+	}
+
+	for _, glob := range o.include {
+		if ok, _ := path.Match(glob, path.Base(rel)); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// extension returns the group of file in the summary by extension
+func extension(file string) string {
+	ext := strings.ToLower(filepath.Ext(file))
+	if ext == "" || ext == filepath.Base(file) {
+		return "(none)"
+	}
+
+	return ext
+}
@@ -0,0 +1,405 @@
+// Package wc counts the bytes, characters, words and lines of a stream,
+// the way GNU wc does in a UTF-8 locale, without holding the input in memory.
+// UTF-16 and Latin-1 streams are supported too.
+package wc
+
+import (
+	"cmp"
+	"io"
+	"slices"
+	"unicode"
+	"unicode/utf8"
+)
+
+// bufSize is the size of the chunks read by ReadFrom
+const bufSize = 64 * 1024
+
+// Counts holds the totals of a stream
+type Counts struct {
+	Bytes         int64
+	Chars         int64
+	Words         int64
+	Lines         int64
+	MaxLineLength int64
+	BlankLines    int64 // terminated lines without any word
+}
+
+// Add sums the totals of o into c, the maximum line length is the longest
+// of both
+func (c *Counts) Add(o Counts) {
+	c.Bytes += o.Bytes
+	c.Chars += o.Chars
+	c.Words += o.Words
+	c.Lines += o.Lines
+	c.MaxLineLength = max(c.MaxLineLength, o.MaxLineLength)
+	c.BlankLines += o.BlankLines
+}
+
+// WordCount is a word and the number of times it was found
+type WordCount struct {
+	Word  string
+	Count int64
+}
+
+// Counter keeps the running totals of a stream. It is fed chunk by chunk
+// through Write, so the input is never held in memory, and it keeps the
+// state needed for words and runes that cross two chunks.
+//
+// The zero value is ready to use, it reads UTF-8 and splits words with
+// UnicodeSpace. The exported fields must not be changed after the first write.
+type Counter struct {
+	// Rule splits the words, nil means UnicodeSpace
+	Rule WordRule
+
+	// Encoding of the stream, UTF8 by default
+	Encoding Encoding
+
+	// Invalid, if not nil, is called with every invalid byte sequence and
+	// its offset in the stream. Such sequences are neither characters nor
+	// part of a word.
+	Invalid func(offset int64, seq []byte)
+
+	// WordFreq keeps the number of occurrences of every word, returned by
+	// WordFrequencies. It is off by default, the words are held in memory.
+	WordFreq bool
+
+	// FoldCase counts the words of WordFrequencies in lower case
+	FoldCase bool
+
+	counts   Counts // MaxLineLength only holds the terminated lines
+	linePos  int64  // display width of the current line
+	lineWord bool   // a word was found in the current line
+	offset   int64  // bytes decoded so far, pending ones excluded
+
+	word []byte // current word, with WordFreq
+	freq map[string]int64
+
+	enc      Encoding // Encoding, once detected when it is Auto
+	detected bool
+
+	inWord   bool
+	started  bool    // a rune changed the word state
+	leadWord bool    // that first rune started a word
+	pending  [4]byte // first bytes of a sequence split by the chunk boundary
+	npending int
+}
+
+// NewCounter returns a UTF-8 counter splitting words with rule, nil means
+// UnicodeSpace
+func NewCounter(rule WordRule) *Counter {
+	return &Counter{Rule: rule}
+}
+
+// Reset clears the totals and the state of the counter, keeping its
+// configuration, to count a new stream
+func (c *Counter) Reset() {
+	*c = Counter{
+		Rule:     c.Rule,
+		Encoding: c.Encoding,
+		Invalid:  c.Invalid,
+		WordFreq: c.WordFreq,
+		FoldCase: c.FoldCase,
+	}
+}
+
+// Counts returns the totals of everything written so far. The last line
+// is not a blank line until it is terminated.
+func (c *Counter) Counts() Counts {
+	counts := c.counts
+	counts.MaxLineLength = max(counts.MaxLineLength, c.linePos)
+
+	return counts
+}
+
+// WordFrequencies returns the number of occurrences of every word written
+// so far, the last word included, or nil when WordFreq is off. The map is
+// a copy the caller may modify.
+func (c *Counter) WordFrequencies() map[string]int64 {
+	if !c.WordFreq {
+		return nil
+	}
+
+	freq := make(map[string]int64, len(c.freq)+1)
+	for word, n := range c.freq {
+		freq[word] = n
+	}
+	if c.inWord && len(c.word) > 0 {
+		freq[string(c.word)]++
+	}
+
+	return freq
+}
+
+// TopWords returns the n most frequent words of freq, the most frequent
+// first and the words found as many times in lexical order. Every word is
+// returned when n is not positive.
+func TopWords(freq map[string]int64, n int) []WordCount {
+	words := make([]WordCount, 0, len(freq))
+	for word, count := range freq {
+		words = append(words, WordCount{word, count})
+	}
+
+	slices.SortFunc(words, func(a, b WordCount) int {
+		if a.Count != b.Count {
+			return cmp.Compare(b.Count, a.Count)
+		}
+		return cmp.Compare(a.Word, b.Word)
+	})
+
+	if n > 0 && n < len(words) {
+		words = words[:n]
+	}
+
+	return words
+}
+
+// Write counts p, it never fails
+func (c *Counter) Write(p []byte) (int, error) {
+	n := len(p)
+	c.counts.Bytes += int64(n)
+
+	if !c.detected {
+		if p = c.detect(p, false); !c.detected {
+			return n, nil
+		}
+	}
+
+	// finish the sequence started in the previous chunk
+	for c.npending > 0 {
+		m := copy(c.pending[c.npending:], p)
+		size := c.step(c.pending[:c.npending+m])
+		if size == 0 {
+			// all of p fitted in pending, it is still too short
+			c.npending += m
+			return n, nil
+		}
+
+		if size <= c.npending {
+			copy(c.pending[:], c.pending[size:c.npending])
+			c.npending -= size
+			continue
+		}
+
+		p = p[size-c.npending:]
+		c.npending = 0
+	}
+
+	for len(p) > 0 {
+		if c.enc == UTF8 && p[0] < utf8.RuneSelf {
+			c.rune(rune(p[0]))
+			c.offset++
+			p = p[1:]
+			continue
+		}
+
+		size := c.step(p)
+		if size == 0 {
+			c.npending = copy(c.pending[:], p)
+			break
+		}
+		p = p[size:]
+	}
+
+	return n, nil
+}
+
+// Close reports the sequence left incomplete at the end of the stream as
+// invalid, it never fails
+func (c *Counter) Close() error {
+	if !c.detected {
+		c.detect(nil, true)
+	}
+
+	for c.npending > 0 {
+		size := c.step(c.pending[:c.npending])
+		if size == 0 {
+			c.invalid(c.pending[:c.npending])
+			size = c.npending
+		}
+
+		copy(c.pending[:], c.pending[size:c.npending])
+		c.npending -= size
+	}
+
+	return nil
+}
+
+// ReadFrom counts r until EOF, reading it in fixed size chunks
+func (c *Counter) ReadFrom(r io.Reader) (int64, error) {
+	var total int64
+	buf := make([]byte, bufSize)
+
+	for {
+		n, err := r.Read(buf)
+		c.Write(buf[:n])
+		total += int64(n)
+
+		if err == io.EOF {
+			return total, nil
+		}
+		if err != nil {
+			return total, err
+		}
+	}
+}
+
+// Join appends the counters of next, that counted the bytes directly
+// following the ones counted by c, so a UTF-8 stream can be split in ranges
+// counted in parallel. The ranges must not split a UTF-8 sequence and a
+// word that spans both of them is counted once. The maximum line length,
+// the blank lines and the word frequencies are not joined, they depend on
+// the line or the word where next starts.
+func (c *Counter) Join(next *Counter) {
+	c.counts.Bytes += next.counts.Bytes
+	c.counts.Chars += next.counts.Chars
+	c.counts.Words += next.counts.Words
+	c.counts.Lines += next.counts.Lines
+	c.offset += next.offset
+
+	if c.inWord && next.leadWord {
+		c.counts.Words--
+	}
+
+	if next.started {
+		c.inWord = next.inWord
+	}
+	if !c.started {
+		c.started = next.started
+		c.leadWord = next.leadWord
+	}
+}
+
+// detect sets the encoding of the stream before the first rune is decoded.
+// With Auto the first bytes of the stream are gathered in pending until the
+// byte order mark, if any, can be told, the rest of p is returned.
+func (c *Counter) detect(p []byte, atEOF bool) []byte {
+	if c.Encoding != Auto {
+		c.enc = c.Encoding
+		c.detected = true
+		return p
+	}
+
+	m := copy(c.pending[c.npending:len(bomUTF8)], p)
+	c.npending += m
+	head := c.pending[:c.npending]
+
+	if !atEOF && len(head) < len(bomUTF8) && isBOMPrefix(head) {
+		return p[m:]
+	}
+
+	enc, bom := detectBOM(head)
+	c.enc = enc
+	c.detected = true
+
+	copy(c.pending[:], head[bom:])
+	c.npending -= bom
+	c.offset += int64(bom)
+
+	return p[m:]
+}
+
+// step decodes and counts the first sequence of p, it returns its size or
+// 0 when p is too short to hold a whole sequence
+func (c *Counter) step(p []byte) int {
+	r, size := c.enc.decode(p)
+	if size == 0 {
+		return 0
+	}
+
+	if r == invalidRune {
+		c.invalid(p[:size])
+	} else {
+		c.rune(r)
+		c.offset += int64(size)
+	}
+
+	return size
+}
+
+// invalid reports an invalid sequence, it is not a character and does not
+// change the word state
+func (c *Counter) invalid(seq []byte) {
+	if c.Invalid != nil {
+		c.Invalid(c.offset, seq)
+	}
+
+	c.offset += int64(len(seq))
+}
+
+// rune updates the counters with a decoded rune
+func (c *Counter) rune(r rune) {
+	c.counts.Chars++
+	printable := isPrintable(r)
+
+	switch r {
+	case '\n':
+		c.counts.Lines++
+		if !c.lineWord {
+			c.counts.BlankLines++
+		}
+		c.lineWord = false
+		fallthrough
+
+	case '\r', '\f':
+		c.counts.MaxLineLength = max(c.counts.MaxLineLength, c.linePos)
+		c.linePos = 0
+
+	case '\t':
+		c.linePos += 8 - c.linePos%8
+
+	default:
+		if printable {
+			c.linePos += runeWidth(r)
+		}
+	}
+
+	var class Class
+	if c.Rule == nil {
+		class = classify(r, printable)
+	} else {
+		class = c.Rule(r)
+	}
+
+	switch class {
+	case Separator:
+		if c.WordFreq && c.inWord {
+			c.addWord()
+		}
+		c.setInWord(false)
+
+	case WordChar:
+		c.setInWord(true)
+		c.lineWord = true
+
+		if c.WordFreq {
+			if c.FoldCase {
+				r = unicode.ToLower(r)
+			}
+			c.word = utf8.AppendRune(c.word, r)
+		}
+	}
+}
+
+// addWord counts the word that just ended in the word frequencies
+func (c *Counter) addWord() {
+	if c.freq == nil {
+		c.freq = make(map[string]int64)
+	}
+
+	c.freq[string(c.word)]++
+	c.word = c.word[:0]
+}
+
+// setInWord records the word state after a rune, counting a new word when
+// one starts
+func (c *Counter) setInWord(in bool) {
+	if !c.started {
+		c.started = true
+		c.leadWord = in
+	}
+
+	if in && !c.inWord {
+		c.counts.Words++
+	}
+	c.inWord = in
+}
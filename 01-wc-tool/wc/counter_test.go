@@ -0,0 +1,411 @@
+package wc_test
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode"
+	"wc/wc"
+)
+
+func TestCounter_ReadFrom(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		want wc.Counts
+	}{
+		{
+			name: "test file",
+			file: "../test.txt",
+			want: wc.Counts{Bytes: 335045, Chars: 332147, Words: 58164, Lines: 7145, MaxLineLength: 78, BlankLines: 1718},
+		},
+		{
+			name: "new file",
+			file: "../new.txt",
+			want: wc.Counts{Bytes: 7, Chars: 7, Words: 2, Lines: 1, MaxLineLength: 3},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := os.Open(tt.file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+
+			c := wc.NewCounter(nil)
+			if _, err := c.ReadFrom(f); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := c.Counts(); got != tt.want {
+				t.Errorf("Counts() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCounter_Write(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		rule  wc.WordRule
+		want  wc.Counts
+	}{
+		{
+			name:  "empty",
+			input: "",
+			want:  wc.Counts{},
+		},
+		{
+			name:  "no trailing newline",
+			input: "one two\nthree",
+			want:  wc.Counts{Bytes: 13, Chars: 13, Words: 3, Lines: 1, MaxLineLength: 7},
+		},
+		{
+			name:  "multi byte runes",
+			input: "ação 中文\n",
+			want:  wc.Counts{Bytes: 14, Chars: 8, Words: 2, Lines: 1, MaxLineLength: 9},
+		},
+		{
+			name:  "invalid bytes are not characters",
+			input: "x \xff\xfe y",
+			want:  wc.Counts{Bytes: 6, Chars: 4, Words: 2, Lines: 0, MaxLineLength: 4},
+		},
+		{
+			name:  "non-breaking space separates words",
+			input: "a\u00a0b",
+			want:  wc.Counts{Bytes: 4, Chars: 3, Words: 2, MaxLineLength: 3},
+		},
+		{
+			name:  "non printable runes keep the word",
+			input: "a\x01b \x01",
+			want:  wc.Counts{Bytes: 5, Chars: 5, Words: 1, MaxLineLength: 3},
+		},
+		{
+			name:  "wide and combining runes",
+			input: "e\u0301 ｗｉｄｅ 한\u1161\n",
+			want:  wc.Counts{Bytes: 24, Chars: 11, Words: 3, Lines: 1, MaxLineLength: 13},
+		},
+		{
+			name:  "blank lines",
+			input: "a\n\n \t\n\x01\nb\n  ",
+			want:  wc.Counts{Bytes: 12, Chars: 12, Words: 2, Lines: 5, MaxLineLength: 8, BlankLines: 3},
+		},
+		{
+			name:  "tabs",
+			input: "a\tb\n",
+			want:  wc.Counts{Bytes: 4, Chars: 4, Words: 2, Lines: 1, MaxLineLength: 9},
+		},
+		{
+			name:  "ascii space rule",
+			input: "a b \x01",
+			rule:  wc.ASCIISpace,
+			want:  wc.Counts{Bytes: 6, Chars: 5, Words: 2, MaxLineLength: 4},
+		},
+		{
+			name:  "custom split",
+			input: "a,b;c d",
+			rule:  wc.SplitFunc(unicode.IsPunct),
+			want:  wc.Counts{Bytes: 7, Chars: 7, Words: 3, MaxLineLength: 7},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := wc.NewCounter(tt.rule)
+			c.Write([]byte(tt.input))
+
+			if got := c.Counts(); got != tt.want {
+				t.Errorf("Counts() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCounter_WriteByteByByte(t *testing.T) {
+	input, err := os.ReadFile("../test.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	input = append(input, "ação \xe4\xb8 中文\xff"...)
+
+	whole := wc.NewCounter(nil)
+	whole.Write(input)
+
+	split := wc.NewCounter(nil)
+	for i := range input {
+		split.Write(input[i : i+1])
+	}
+
+	if got, want := split.Counts(), whole.Counts(); got != want {
+		t.Errorf("Counts() = %+v, want %+v", got, want)
+	}
+}
+
+func TestCounter_Join(t *testing.T) {
+	input := []byte("  one two\tação\n\x01x  中文 end")
+
+	whole := wc.NewCounter(nil)
+	whole.Write(input)
+	want := whole.Counts()
+
+	for i := 0; i <= len(input); i++ {
+		// ranges never start with a continuation byte
+		if i < len(input) && input[i]&0xC0 == 0x80 {
+			continue
+		}
+
+		left := wc.NewCounter(nil)
+		left.Write(input[:i])
+		right := wc.NewCounter(nil)
+		right.Write(input[i:])
+		left.Join(right)
+
+		got := left.Counts()
+		got.MaxLineLength = want.MaxLineLength
+		if got != want {
+			t.Errorf("split at %d: Counts() = %+v, want %+v", i, got, want)
+		}
+	}
+}
+
+func TestCounts_Add(t *testing.T) {
+	total := wc.Counts{Bytes: 1, Chars: 1, Words: 1, Lines: 1, MaxLineLength: 10, BlankLines: 1}
+	total.Add(wc.Counts{Bytes: 2, Chars: 2, Words: 2, Lines: 2, MaxLineLength: 5, BlankLines: 1})
+
+	want := wc.Counts{Bytes: 3, Chars: 3, Words: 3, Lines: 3, MaxLineLength: 10, BlankLines: 2}
+	if total != want {
+		t.Errorf("Add() = %+v, want %+v", total, want)
+	}
+}
+
+func TestCounter_ZeroValue(t *testing.T) {
+	var c wc.Counter
+	c.Write([]byte("one\u00a0two"))
+
+	if got := c.Counts().Words; got != 2 {
+		t.Errorf("Words = %d, want 2", got)
+	}
+}
+
+func TestCounter_Encoding(t *testing.T) {
+	type invalid struct {
+		offset int64
+		seq    string
+	}
+	tests := []struct {
+		name        string
+		input       string
+		encoding    wc.Encoding
+		want        wc.Counts
+		wantInvalid []invalid
+	}{
+		{
+			name:     "utf-16le",
+			input:    "a\x00 \x00\xe9\x00\n\x00",
+			encoding: wc.UTF16LE,
+			want:     wc.Counts{Bytes: 8, Chars: 4, Words: 2, Lines: 1, MaxLineLength: 3},
+		},
+		{
+			name:     "utf-16be surrogate pair",
+			input:    "\xd8\x3d\xde\x00\x00 \x00b",
+			encoding: wc.UTF16BE,
+			want:     wc.Counts{Bytes: 8, Chars: 3, Words: 2, MaxLineLength: 4},
+		},
+		{
+			name:        "utf-16le unpaired surrogate and odd byte",
+			input:       "a\x00\x00\xdcb\x00c",
+			encoding:    wc.UTF16LE,
+			want:        wc.Counts{Bytes: 7, Chars: 2, Words: 1, MaxLineLength: 2},
+			wantInvalid: []invalid{{2, "\x00\xdc"}, {6, "c"}},
+		},
+		{
+			name:     "latin1",
+			input:    "caf\xe9\xa0bar",
+			encoding: wc.Latin1,
+			want:     wc.Counts{Bytes: 8, Chars: 8, Words: 2, MaxLineLength: 8},
+		},
+		{
+			name:     "auto utf-8 bom",
+			input:    "\xef\xbb\xbfé",
+			encoding: wc.Auto,
+			want:     wc.Counts{Bytes: 5, Chars: 1, Words: 1, MaxLineLength: 1},
+		},
+		{
+			name:     "auto utf-16le bom",
+			input:    "\xff\xfea\x00b\x00",
+			encoding: wc.Auto,
+			want:     wc.Counts{Bytes: 6, Chars: 2, Words: 1, MaxLineLength: 2},
+		},
+		{
+			name:     "auto utf-16be bom",
+			input:    "\xfe\xff\x00a",
+			encoding: wc.Auto,
+			want:     wc.Counts{Bytes: 4, Chars: 1, Words: 1, MaxLineLength: 1},
+		},
+		{
+			name:        "auto without bom",
+			input:       "\xef\xbb",
+			encoding:    wc.Auto,
+			want:        wc.Counts{Bytes: 2},
+			wantInvalid: []invalid{{0, "\xef\xbb"}},
+		},
+		{
+			name:        "utf-8 invalid bytes",
+			input:       "a\xffb\xe4\xb8",
+			encoding:    wc.UTF8,
+			want:        wc.Counts{Bytes: 5, Chars: 2, Words: 1, MaxLineLength: 2},
+			wantInvalid: []invalid{{1, "\xff"}, {3, "\xe4\xb8"}},
+		},
+	}
+	for _, tt := range tests {
+		for _, chunk := range []int{1, 2, 3, len(tt.input) + 1} {
+			t.Run(tt.name, func(t *testing.T) {
+				var got []invalid
+
+				c := &wc.Counter{
+					Encoding: tt.encoding,
+					Invalid: func(offset int64, seq []byte) {
+						got = append(got, invalid{offset, string(seq)})
+					},
+				}
+
+				input := []byte(tt.input)
+				for len(input) > 0 {
+					n := min(chunk, len(input))
+					c.Write(input[:n])
+					input = input[n:]
+				}
+				c.Close()
+
+				if counts := c.Counts(); counts != tt.want {
+					t.Errorf("chunk %d: Counts() = %+v, want %+v", chunk, counts, tt.want)
+				}
+				if !reflect.DeepEqual(got, tt.wantInvalid) {
+					t.Errorf("chunk %d: invalid = %q, want %q", chunk, got, tt.wantInvalid)
+				}
+			})
+		}
+	}
+}
+
+func TestParseEncoding(t *testing.T) {
+	for _, e := range []wc.Encoding{wc.UTF8, wc.UTF16LE, wc.UTF16BE, wc.Latin1, wc.Auto} {
+		got, err := wc.ParseEncoding(strings.ToUpper(e.String()))
+		if err != nil || got != e {
+			t.Errorf("ParseEncoding(%q) = %v, %v, want %v", e.String(), got, err, e)
+		}
+	}
+
+	if _, err := wc.ParseEncoding("ebcdic"); err == nil {
+		t.Error("ParseEncoding(\"ebcdic\") succeeded")
+	}
+}
+
+func TestCounter_Reset(t *testing.T) {
+	c := &wc.Counter{Encoding: wc.Auto}
+	c.Write([]byte("\xff\xfea\x00"))
+	c.Reset()
+	c.Write([]byte("a b\n"))
+
+	want := wc.Counts{Bytes: 4, Chars: 4, Words: 2, Lines: 1, MaxLineLength: 3}
+	if got := c.Counts(); got != want {
+		t.Errorf("Counts() = %+v, want %+v", got, want)
+	}
+}
+
+func TestCounter_WordFrequencies(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		foldCase bool
+		want     map[string]int64
+	}{
+		{
+			name:  "empty",
+			input: "",
+			want:  map[string]int64{},
+		},
+		{
+			name:  "last word without separator",
+			input: "the cat\nthe dog the",
+			want:  map[string]int64{"the": 3, "cat": 1, "dog": 1},
+		},
+		{
+			name:  "case is kept",
+			input: "Go go GO",
+			want:  map[string]int64{"Go": 1, "go": 1, "GO": 1},
+		},
+		{
+			name:     "case folding",
+			input:    "Go go GO Ação AÇÃO",
+			foldCase: true,
+			want:     map[string]int64{"go": 3, "ação": 2},
+		},
+		{
+			name:  "non printable and invalid bytes are left out",
+			input: "a\x01b ab a\xffb",
+			want:  map[string]int64{"ab": 3},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, chunk := range []int{1, 2, len(tt.input)} {
+				c := &wc.Counter{WordFreq: true, FoldCase: tt.foldCase}
+				for input := []byte(tt.input); len(input) > 0; {
+					n := min(chunk, len(input))
+					c.Write(input[:n])
+					input = input[n:]
+				}
+				c.Close()
+
+				if got := c.WordFrequencies(); !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("chunk %d: WordFrequencies() = %v, want %v", chunk, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestCounter_WordFrequenciesOff(t *testing.T) {
+	c := wc.NewCounter(nil)
+	c.Write([]byte("one two"))
+
+	if got := c.WordFrequencies(); got != nil {
+		t.Errorf("WordFrequencies() = %v, want nil", got)
+	}
+}
+
+func TestTopWords(t *testing.T) {
+	freq := map[string]int64{"b": 2, "a": 2, "c": 5, "d": 1}
+
+	tests := []struct {
+		name string
+		n    int
+		want []wc.WordCount
+	}{
+		{
+			name: "top 3",
+			n:    3,
+			want: []wc.WordCount{{"c", 5}, {"a", 2}, {"b", 2}},
+		},
+		{
+			name: "more than the words",
+			n:    10,
+			want: []wc.WordCount{{"c", 5}, {"a", 2}, {"b", 2}, {"d", 1}},
+		},
+		{
+			name: "all",
+			n:    0,
+			want: []wc.WordCount{{"c", 5}, {"a", 2}, {"b", 2}, {"d", 1}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := wc.TopWords(freq, tt.n); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("TopWords() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package wc
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Encoding is the character encoding of a stream
+type Encoding int
+
+const (
+	UTF8 Encoding = iota
+	UTF16LE
+	UTF16BE
+	Latin1
+
+	// Auto detects UTF-8, UTF-16LE and UTF-16BE from the byte order mark at
+	// the start of the stream, falling back to UTF-8 when there is none.
+	// The byte order mark is not counted as a character.
+	Auto
+)
+
+var encodingNames = map[Encoding]string{
+	UTF8:    "utf-8",
+	UTF16LE: "utf-16le",
+	UTF16BE: "utf-16be",
+	Latin1:  "latin1",
+	Auto:    "auto",
+}
+
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+// invalidRune is returned by decode for an invalid byte sequence
+const invalidRune = -1
+
+func (e Encoding) String() string {
+	if name, ok := encodingNames[e]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("Encoding(%d)", int(e))
+}
+
+// ParseEncoding returns the encoding with the given name, case insensitive.
+// Besides the names returned by String it accepts utf8, utf16le, utf16be,
+// iso-8859-1 and latin-1.
+func ParseEncoding(name string) (Encoding, error) {
+	switch strings.ToLower(name) {
+	case "utf-8", "utf8":
+		return UTF8, nil
+	case "utf-16le", "utf16le":
+		return UTF16LE, nil
+	case "utf-16be", "utf16be":
+		return UTF16BE, nil
+	case "latin1", "latin-1", "iso-8859-1":
+		return Latin1, nil
+	case "auto":
+		return Auto, nil
+	}
+
+	return UTF8, fmt.Errorf("unknown encoding %q", name)
+}
+
+// decode returns the first rune of p and its size in bytes. The size is 0
+// when p is too short to tell, more bytes are needed, and the rune is
+// invalidRune when p starts with an invalid sequence.
+func (e Encoding) decode(p []byte) (rune, int) {
+	switch e {
+	case Latin1:
+		return rune(p[0]), 1
+
+	case UTF16LE, UTF16BE:
+		if len(p) < 2 {
+			return 0, 0
+		}
+
+		r := e.unit(p)
+		if !utf16.IsSurrogate(r) {
+			return r, 2
+		}
+		if r >= 0xDC00 {
+			// low surrogate without the high one
+			return invalidRune, 2
+		}
+
+		if len(p) < 4 {
+			return 0, 0
+		}
+		if r = utf16.DecodeRune(r, e.unit(p[2:])); r == utf8.RuneError {
+			return invalidRune, 2
+		}
+
+		return r, 4
+	}
+
+	if !utf8.FullRune(p) {
+		return 0, 0
+	}
+
+	r, size := utf8.DecodeRune(p)
+	if r == utf8.RuneError && size == 1 {
+		return invalidRune, 1
+	}
+
+	return r, size
+}
+
+// unit returns the first UTF-16 code unit of p
+func (e Encoding) unit(p []byte) rune {
+	if e == UTF16BE {
+		return rune(p[0])<<8 | rune(p[1])
+	}
+
+	return rune(p[1])<<8 | rune(p[0])
+}
+
+// detectBOM returns the encoding given by the byte order mark at the start
+// of head and the size of the mark
+func detectBOM(head []byte) (Encoding, int) {
+	switch {
+	case bytes.HasPrefix(head, bomUTF8):
+		return UTF8, len(bomUTF8)
+	case bytes.HasPrefix(head, bomUTF16LE):
+		return UTF16LE, len(bomUTF16LE)
+	case bytes.HasPrefix(head, bomUTF16BE):
+		return UTF16BE, len(bomUTF16BE)
+	}
+
+	return UTF8, 0
+}
+
+// isBOMPrefix reports whether head may be the start of a byte order mark
+func isBOMPrefix(head []byte) bool {
+	return bytes.HasPrefix(bomUTF8, head) || bytes.HasPrefix(bomUTF16LE, head) || bytes.HasPrefix(bomUTF16BE, head)
+}
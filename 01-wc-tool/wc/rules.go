@@ -0,0 +1,88 @@
+package wc
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// Class is the role of a rune when counting words
+type Class int
+
+const (
+	// Neutral runes neither start nor end a word
+	Neutral Class = iota
+	// Separator runes end the current word
+	Separator
+	// WordChar runes start a new word or continue the current one
+	WordChar
+)
+
+// WordRule classifies runes to find the word boundaries
+type WordRule func(r rune) Class
+
+var (
+	// ASCIISpace separates words with the ASCII white space characters
+	// only, any other rune is part of a word
+	ASCIISpace WordRule = asciiSpace
+
+	// UnicodeSpace follows GNU wc in a UTF-8 locale: words are separated by
+	// Unicode white space, non-breaking spaces included, and non printable
+	// characters do not change the word state. It is the default rule.
+	UnicodeSpace WordRule = unicodeSpace
+)
+
+// SplitFunc returns a rule where the runes for which isSpace returns true
+// separate words and any other rune is part of a word, like
+// strings.FieldsFunc
+func SplitFunc(isSpace func(r rune) bool) WordRule {
+	return func(r rune) Class {
+		if isSpace(r) {
+			return Separator
+		}
+
+		return WordChar
+	}
+}
+
+func asciiSpace(r rune) Class {
+	switch r {
+	case ' ', '\t', '\n', '\v', '\f', '\r':
+		return Separator
+	}
+
+	return WordChar
+}
+
+func unicodeSpace(r rune) Class {
+	return classify(r, isPrintable(r))
+}
+
+// classify returns the UnicodeSpace class of r
+func classify(r rune, printable bool) Class {
+	switch r {
+	case ' ', '\t', '\n', '\v', '\f', '\r':
+		return Separator
+
+	case 0x00A0, 0x2007, 0x202F, 0x2060:
+		// non-breaking spaces
+		return Separator
+	}
+
+	if !printable {
+		return Neutral
+	}
+
+	if r >= utf8.RuneSelf && unicode.IsSpace(r) {
+		return Separator
+	}
+
+	return WordChar
+}
+
+func isPrintable(r rune) bool {
+	if r < utf8.RuneSelf {
+		return r >= ' ' && r < 0x7F
+	}
+
+	return unicode.IsGraphic(r) || unicode.Is(unicode.Cf, r)
+}
@@ -0,0 +1,171 @@
+package wc
+
+import "unicode"
+
+// runeWidth returns the number of columns taken by a printable rune, like
+// wcwidth does in a glibc UTF-8 locale: 2 for the East Asian wide and
+// fullwidth characters, 0 for the combining marks, the format characters
+// and the Hangul medial vowels and final consonants, 1 otherwise
+func runeWidth(r rune) int64 {
+	if r < 0x0300 {
+		return 1
+	}
+
+	if unicode.Is(wide, r) {
+		return 2
+	}
+
+	if unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf) && !unicode.Is(prependedMarks, r) ||
+		r >= 0x1160 && r <= 0x11FF || r >= 0xD7B0 && r <= 0xD7FF {
+		return 0
+	}
+
+	return 1
+}
+
+// prependedMarks are the format characters shown as a sign spanning the
+// digits that follow, they take a column
+var prependedMarks = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{0x0600, 0x0605, 1},
+		{0x06DD, 0x06DD, 1},
+		{0x070F, 0x070F, 1},
+		{0x0890, 0x0891, 1},
+		{0x08E2, 0x08E2, 1},
+	},
+	R32: []unicode.Range32{
+		{0x110BD, 0x110BD, 1},
+		{0x110CD, 0x110CD, 1},
+	},
+}
+
+// wide are the characters of East Asian width W and F, taking two columns.
+// The unassigned code points of the CJK blocks and of planes 2 and 3 are
+// included, as in Unicode EastAsianWidth.txt.
+var wide = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{0x1100, 0x115F, 1},
+		{0x231A, 0x231B, 1},
+		{0x2329, 0x232A, 1},
+		{0x23E9, 0x23EC, 1},
+		{0x23F0, 0x23F0, 1},
+		{0x23F3, 0x23F3, 1},
+		{0x25FD, 0x25FE, 1},
+		{0x2614, 0x2615, 1},
+		{0x2648, 0x2653, 1},
+		{0x267F, 0x267F, 1},
+		{0x2693, 0x2693, 1},
+		{0x26A1, 0x26A1, 1},
+		{0x26AA, 0x26AB, 1},
+		{0x26BD, 0x26BE, 1},
+		{0x26C4, 0x26C5, 1},
+		{0x26CE, 0x26CE, 1},
+		{0x26D4, 0x26D4, 1},
+		{0x26EA, 0x26EA, 1},
+		{0x26F2, 0x26F3, 1},
+		{0x26F5, 0x26F5, 1},
+		{0x26FA, 0x26FA, 1},
+		{0x26FD, 0x26FD, 1},
+		{0x2705, 0x2705, 1},
+		{0x270A, 0x270B, 1},
+		{0x2728, 0x2728, 1},
+		{0x274C, 0x274C, 1},
+		{0x274E, 0x274E, 1},
+		{0x2753, 0x2755, 1},
+		{0x2757, 0x2757, 1},
+		{0x2795, 0x2797, 1},
+		{0x27B0, 0x27B0, 1},
+		{0x27BF, 0x27BF, 1},
+		{0x2B1B, 0x2B1C, 1},
+		{0x2B50, 0x2B50, 1},
+		{0x2B55, 0x2B55, 1},
+		{0x2E80, 0x2E99, 1},
+		{0x2E9B, 0x2EF3, 1},
+		{0x2F00, 0x2FD5, 1},
+		{0x2FF0, 0x2FFB, 1},
+		{0x3000, 0x3029, 1},
+		{0x302E, 0x303E, 1},
+		{0x3041, 0x3096, 1},
+		{0x309B, 0x30FF, 1},
+		{0x3105, 0x312F, 1},
+		{0x3131, 0x318E, 1},
+		{0x3190, 0x31E3, 1},
+		{0x31F0, 0x321E, 1},
+		{0x3220, 0xA48C, 1},
+		{0xA490, 0xA4C6, 1},
+		{0xA960, 0xA97C, 1},
+		{0xAC00, 0xD7A3, 1},
+		{0xF900, 0xFA6D, 1},
+		{0xFA70, 0xFAD9, 1},
+		{0xFE10, 0xFE19, 1},
+		{0xFE30, 0xFE52, 1},
+		{0xFE54, 0xFE66, 1},
+		{0xFE68, 0xFE6B, 1},
+		{0xFF01, 0xFF60, 1},
+		{0xFFE0, 0xFFE6, 1},
+	},
+	R32: []unicode.Range32{
+		{0x16FE0, 0x16FE3, 1},
+		{0x16FF0, 0x16FF1, 1},
+		{0x17000, 0x187F7, 1},
+		{0x18800, 0x18CD5, 1},
+		{0x18D00, 0x18D08, 1},
+		{0x1AFF0, 0x1AFF3, 1},
+		{0x1AFF5, 0x1AFFB, 1},
+		{0x1AFFD, 0x1AFFE, 1},
+		{0x1B000, 0x1B122, 1},
+		{0x1B150, 0x1B152, 1},
+		{0x1B164, 0x1B167, 1},
+		{0x1B170, 0x1B2FB, 1},
+		{0x1F004, 0x1F004, 1},
+		{0x1F0CF, 0x1F0CF, 1},
+		{0x1F18E, 0x1F18E, 1},
+		{0x1F191, 0x1F19A, 1},
+		{0x1F200, 0x1F202, 1},
+		{0x1F210, 0x1F23B, 1},
+		{0x1F240, 0x1F248, 1},
+		{0x1F250, 0x1F251, 1},
+		{0x1F260, 0x1F265, 1},
+		{0x1F300, 0x1F320, 1},
+		{0x1F32D, 0x1F335, 1},
+		{0x1F337, 0x1F37C, 1},
+		{0x1F37E, 0x1F393, 1},
+		{0x1F3A0, 0x1F3CA, 1},
+		{0x1F3CF, 0x1F3D3, 1},
+		{0x1F3E0, 0x1F3F0, 1},
+		{0x1F3F4, 0x1F3F4, 1},
+		{0x1F3F8, 0x1F43E, 1},
+		{0x1F440, 0x1F440, 1},
+		{0x1F442, 0x1F4FC, 1},
+		{0x1F4FF, 0x1F53D, 1},
+		{0x1F54B, 0x1F54E, 1},
+		{0x1F550, 0x1F567, 1},
+		{0x1F57A, 0x1F57A, 1},
+		{0x1F595, 0x1F596, 1},
+		{0x1F5A4, 0x1F5A4, 1},
+		{0x1F5FB, 0x1F64F, 1},
+		{0x1F680, 0x1F6C5, 1},
+		{0x1F6CC, 0x1F6CC, 1},
+		{0x1F6D0, 0x1F6D2, 1},
+		{0x1F6D5, 0x1F6D7, 1},
+		{0x1F6DD, 0x1F6DF, 1},
+		{0x1F6EB, 0x1F6EC, 1},
+		{0x1F6F4, 0x1F6FC, 1},
+		{0x1F7E0, 0x1F7EB, 1},
+		{0x1F7F0, 0x1F7F0, 1},
+		{0x1F90C, 0x1F93A, 1},
+		{0x1F93C, 0x1F945, 1},
+		{0x1F947, 0x1F9FF, 1},
+		{0x1FA70, 0x1FA74, 1},
+		{0x1FA78, 0x1FA7C, 1},
+		{0x1FA80, 0x1FA86, 1},
+		{0x1FA90, 0x1FAAC, 1},
+		{0x1FAB0, 0x1FABA, 1},
+		{0x1FAC0, 0x1FAC5, 1},
+		{0x1FAD0, 0x1FAD9, 1},
+		{0x1FAE0, 0x1FAE7, 1},
+		{0x1FAF0, 0x1FAF6, 1},
+		{0x20000, 0x2FFFD, 1},
+		{0x30000, 0x3FFFD, 1},
+	},
+}
@@ -0,0 +1,244 @@
+// Package ast declares the types of the tree of a parsed JSON document
+package ast
+
+import "jp/token"
+
+// Kind is the type of a JSON value
+type Kind int
+
+const (
+	NullKind Kind = iota
+	BoolKind
+	NumberKind
+	StringKind
+	ArrayKind
+	ObjectKind
+)
+
+func (k Kind) String() string {
+	switch k {
+	case NullKind:
+		return "null"
+	case BoolKind:
+		return "boolean"
+	case NumberKind:
+		return "number"
+	case StringKind:
+		return "string"
+	case ArrayKind:
+		return "array"
+	case ObjectKind:
+		return "object"
+	}
+
+	return "unknown"
+}
+
+// Node is a JSON value of the tree
+type Node interface {
+	// Pos returns the position of the first character of the value
+	Pos() token.Position
+
+	// Kind returns the type of the value
+	Kind() Kind
+}
+
+// Object is a JSON object, its members are kept in source order
+type Object struct {
+	Position token.Position
+	Members  []*Member
+}
+
+// Member is a key/value pair of an object
+type Member struct {
+	Key   *String
+	Value Node
+}
+
+// Array is a JSON array
+type Array struct {
+	Position token.Position
+	Elements []Node
+}
+
+// String is a JSON string
+type String struct {
+	Position token.Position
+	Value    string
+}
+
+// Number is a JSON number, kept as written in the source
+type Number struct {
+	Position token.Position
+	Literal  string
+}
+
+// Bool is true or false
+type Bool struct {
+	Position token.Position
+	Value    bool
+}
+
+// Null is null
+type Null struct {
+	Position token.Position
+}
+
+func (o *Object) Pos() token.Position { return o.Position }
+func (a *Array) Pos() token.Position  { return a.Position }
+func (s *String) Pos() token.Position { return s.Position }
+func (n *Number) Pos() token.Position { return n.Position }
+func (b *Bool) Pos() token.Position   { return b.Position }
+func (n *Null) Pos() token.Position   { return n.Position }
+
+func (o *Object) Kind() Kind { return ObjectKind }
+func (a *Array) Kind() Kind  { return ArrayKind }
+func (s *String) Kind() Kind { return StringKind }
+func (n *Number) Kind() Kind { return NumberKind }
+func (b *Bool) Kind() Kind   { return BoolKind }
+func (n *Null) Kind() Kind   { return NullKind }
+
+// Len returns the number of members
+func (o *Object) Len() int {
+	return len(o.Members)
+}
+
+// Get returns the value of the member named key. When the key is
+// repeated the last member wins, like encoding/json.
+func (o *Object) Get(key string) (Node, bool) {
+	for i := len(o.Members) - 1; i >= 0; i-- {
+		if o.Members[i].Key.Value == key {
+			return o.Members[i].Value, true
+		}
+	}
+
+	return nil, false
+}
+
+// Keys returns the keys of the members, in source order
+func (o *Object) Keys() []string {
+	keys := make([]string, len(o.Members))
+	for i, m := range o.Members {
+		keys[i] = m.Key.Value
+	}
+
+	return keys
+}
+
+// Len returns the number of elements
+func (a *Array) Len() int {
+	return len(a.Elements)
+}
+
+// Index returns the element at index i, or false when i is out of range
+func (a *Array) Index(i int) (Node, bool) {
+	if i < 0 || i >= len(a.Elements) {
+		return nil, false
+	}
+
+	return a.Elements[i], true
+}
+
+// Lookup follows path from node, a string selects the member of an object
+// and an int the element of an array. It returns false when a step of the
+// path does not exist.
+func Lookup(node Node, path ...any) (Node, bool) {
+	for _, step := range path {
+		var ok bool
+
+		switch step := step.(type) {
+		case string:
+			o, isObject := node.(*Object)
+			if !isObject {
+				return nil, false
+			}
+			node, ok = o.Get(step)
+
+		case int:
+			a, isArray := node.(*Array)
+			if !isArray {
+				return nil, false
+			}
+			node, ok = a.Index(step)
+		}
+
+		if !ok {
+			return nil, false
+		}
+	}
+
+	return node, true
+}
+
+// Equal reports whether a and b are the same JSON value: numbers are
+// compared by value and objects whatever the order of their members, a
+// repeated key counting for its last value
+func Equal(a, b Node) bool {
+	switch a := a.(type) {
+	case *Null:
+		_, ok := b.(*Null)
+		return ok
+
+	case *Bool:
+		b, ok := b.(*Bool)
+		return ok && a.Value == b.Value
+
+	case *String:
+		b, ok := b.(*String)
+		return ok && a.Value == b.Value
+
+	case *Number:
+		b, ok := b.(*Number)
+		if !ok {
+			return false
+		}
+		return equalNumbers(a, b)
+
+	case *Array:
+		b, ok := b.(*Array)
+		if !ok || a.Len() != b.Len() {
+			return false
+		}
+		for i := range a.Elements {
+			if !Equal(a.Elements[i], b.Elements[i]) {
+				return false
+			}
+		}
+		return true
+
+	case *Object:
+		b, ok := b.(*Object)
+		if !ok {
+			return false
+		}
+		keys := uniqueKeys(a)
+		if len(keys) != len(uniqueKeys(b)) {
+			return false
+		}
+		for _, key := range keys {
+			av, _ := a.Get(key)
+			bv, found := b.Get(key)
+			if !found || !Equal(av, bv) {
+				return false
+			}
+		}
+		return true
+	}
+
+	return false
+}
+
+// uniqueKeys returns the keys of obj, once each
+func uniqueKeys(obj *Object) []string {
+	seen := make(map[string]bool, obj.Len())
+	var keys []string
+
+	for _, key := range obj.Keys() {
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
@@ -0,0 +1,250 @@
+package ast
+
+import (
+	"errors"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrRange is a number too large for the type asked for
+	ErrRange = errors.New("number out of range")
+
+	// ErrNotInteger is a number with a fractional part, Infinity or NaN,
+	// asked for as an integer
+	ErrNotInteger = errors.New("number is not an integer")
+)
+
+// maxBigDigits is the number of digits of the largest integer BigInt
+// returns, so that a few bytes like 1e1000000000 do not take gigabytes
+const maxBigDigits = 100000
+
+// maxExponent bounds the decimal exponents, 1e2000000000 is 1e1073741824
+const maxExponent = 1 << 30
+
+// Int64 returns the number as an int64. An integer may be written with a
+// fraction or an exponent, like 1.0 or 1e3, other numbers return
+// ErrNotInteger and the integers beyond the int64 range ErrRange.
+func (n *Number) Int64() (int64, error) {
+	if i, err := strconv.ParseInt(n.Literal, 10, 64); err == nil {
+		return i, nil
+	}
+
+	i, err := n.BigInt()
+	if err != nil {
+		return 0, err
+	}
+	if !i.IsInt64() {
+		return 0, ErrRange
+	}
+
+	return i.Int64(), nil
+}
+
+// Float64 returns the float64 nearest to the number. Like
+// strconv.ParseFloat, a number beyond the float64 range returns ±Inf and
+// ErrRange.
+func (n *Number) Float64() (float64, error) {
+	lit := n.Literal
+	switch {
+	case isHex(lit):
+		// a hexadecimal float needs an exponent
+		lit += "p0"
+	case strings.TrimLeft(lit, "+-") == "NaN":
+		lit = "NaN"
+	}
+
+	f, err := strconv.ParseFloat(lit, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		return f, ErrRange
+	}
+
+	return f, err
+}
+
+// BigInt returns the number as a big.Int. Like Int64, a number with a
+// fractional part returns ErrNotInteger, and an integer of more than
+// 100000 digits returns ErrRange.
+func (n *Number) BigInt() (*big.Int, error) {
+	if isHex(n.Literal) {
+		i, ok := new(big.Int).SetString(n.Literal, 0)
+		if !ok {
+			return nil, strconv.ErrSyntax
+		}
+		return i, nil
+	}
+
+	neg, digits, exp, ok := n.decimal()
+	switch {
+	case !ok:
+		return nil, ErrNotInteger
+	case digits == "":
+		return new(big.Int), nil
+	case exp < 0:
+		return nil, ErrNotInteger
+	case len(digits)+exp > maxBigDigits:
+		return nil, ErrRange
+	}
+
+	i, _ := new(big.Int).SetString(digits+strings.Repeat("0", exp), 10)
+	if neg {
+		i.Neg(i)
+	}
+
+	return i, nil
+}
+
+// BigFloat returns the number as a big.Float, precise enough to hold an
+// integer of any number of digits exactly. NaN, which a big.Float can not
+// hold, returns ErrRange, and so does a number beyond the exponent range
+// of a big.Float, rather than being rounded to ±Inf or 0.
+func (n *Number) BigFloat() (*big.Float, error) {
+	lit := strings.TrimLeft(n.Literal, "+-")
+	_, digits, _, ok := n.decimal()
+
+	switch {
+	case lit == "Infinity":
+		return new(big.Float).SetInf(strings.HasPrefix(n.Literal, "-")), nil
+	case lit == "NaN":
+		return nil, ErrRange
+	}
+
+	// 4 bits a digit are more than the log2(10) needed
+	prec := uint(max(64, 4*len(lit)))
+
+	f, _, err := big.ParseFloat(n.Literal, 0, prec, big.ToNearestEven)
+	if err != nil && ok {
+		// the exponent is beyond the int range
+		if digits == "" {
+			return new(big.Float), nil
+		}
+		return nil, ErrRange
+	}
+	if err != nil {
+		return nil, err
+	}
+	if f.IsInf() || f.Sign() == 0 && digits != "" {
+		return nil, ErrRange
+	}
+
+	return f, nil
+}
+
+// decimal returns the number as ±digits×10^exp, digits without leading or
+// trailing zeros, empty for zero. ok is false for the hexadecimal numbers,
+// Infinity and NaN.
+func (n *Number) decimal() (neg bool, digits string, exp int, ok bool) {
+	lit := n.Literal
+	neg = strings.HasPrefix(lit, "-")
+	lit = strings.TrimLeft(lit, "+-")
+
+	if isHex(lit) || lit == "" || lit[0] != '.' && !isDigit(lit[0]) {
+		return false, "", 0, false
+	}
+
+	mantissa, exponent, _ := strings.Cut(strings.ToLower(lit), "e")
+	intPart, fracPart, _ := strings.Cut(mantissa, ".")
+
+	digits = strings.TrimLeft(intPart+fracPart, "0")
+	if digits == "" {
+		return neg, "", 0, true
+	}
+
+	if exponent != "" {
+		// the exponents are clamped far beyond what a big.Int or a
+		// big.Float can hold
+		e, err := strconv.Atoi(exponent)
+		if err != nil && exponent[0] == '-' {
+			e = -maxExponent
+		} else if err != nil {
+			e = maxExponent
+		}
+		exp = max(-maxExponent, min(e, maxExponent))
+	}
+	exp -= len(fracPart)
+
+	trimmed := strings.TrimRight(digits, "0")
+	exp += len(digits) - len(trimmed)
+
+	return neg, trimmed, exp, true
+}
+
+// equalNumbers reports whether a and b have the same value, exactly for
+// decimal numbers
+func equalNumbers(a, b *Number) bool {
+	aNeg, aDigits, aExp, aOk := a.decimal()
+	bNeg, bDigits, bExp, bOk := b.decimal()
+
+	if aOk && bOk {
+		// -0 is 0
+		return aDigits == bDigits && aExp == bExp && (aNeg == bNeg || aDigits == "")
+	}
+
+	af, _ := a.Float64()
+	bf, _ := b.Float64()
+
+	return af == bf
+}
+
+// Compare returns -1, 0 or +1 as a is less than, equal to or greater than
+// b, exactly for decimal numbers like Equal. The hexadecimal numbers and
+// Infinity are compared as big.Float values, NaN, like a number beyond the
+// exponent range of a big.Float, is neither less nor greater than any
+// number.
+func Compare(a, b *Number) int {
+	aNeg, aDigits, aExp, aOk := a.decimal()
+	bNeg, bDigits, bExp, bOk := b.decimal()
+
+	if !aOk || !bOk {
+		af, aErr := a.BigFloat()
+		bf, bErr := b.BigFloat()
+		if aErr != nil || bErr != nil {
+			return 0
+		}
+		return af.Cmp(bf)
+	}
+
+	aSign, bSign := sign(aNeg, aDigits), sign(bNeg, bDigits)
+	switch {
+	case aSign != bSign && aSign < bSign:
+		return -1
+	case aSign != bSign:
+		return 1
+	}
+
+	// the order of magnitude first, then the digits, which have no trailing
+	// zeros, so a prefix is the smaller number
+	c := strings.Compare(aDigits, bDigits)
+	if am, bm := len(aDigits)+aExp, len(bDigits)+bExp; am != bm {
+		c = 1
+		if am < bm {
+			c = -1
+		}
+	}
+
+	return aSign * c
+}
+
+// sign returns the sign of ±digits, -0 is 0
+func sign(neg bool, digits string) int {
+	switch {
+	case digits == "":
+		return 0
+	case neg:
+		return -1
+	}
+
+	return 1
+}
+
+// isHex reports whether lit is a JSON5 hexadecimal number
+func isHex(lit string) bool {
+	lit = strings.TrimLeft(lit, "+-")
+
+	return strings.HasPrefix(lit, "0x") || strings.HasPrefix(lit, "0X")
+}
+
+func isDigit(ch byte) bool {
+	return '0' <= ch && ch <= '9'
+}
@@ -0,0 +1,222 @@
+// Package format writes the tree of a JSON document back as JSON, indented
+// or minified
+package format
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"jp/ast"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrNonFinite is a JSON5 Infinity or NaN, which JSON has no number for
+var ErrNonFinite = errors.New("Infinity and NaN are not JSON numbers")
+
+// Options tells how the document is written
+type Options struct {
+	// Indent is written once per nesting level before every member and
+	// element, each on its own line. The document is minified, without any
+	// white space, when it is empty.
+	Indent string
+
+	// SortKeys writes the members of the objects sorted by key. Repeated
+	// keys keep their order, so the last one still wins.
+	SortKeys bool
+}
+
+// Write writes node to w as JSON. The strings are escaped as needed and the
+// numbers written as they are in the source, but for the JSON5 ones that
+// JSON lacks, like 0xff, +1, .5 or 5., written in decimal, so parsing the
+// output in any mode gives back an equal tree. Infinity and NaN are written
+// as they are and Write returns ErrNonFinite.
+func Write(w io.Writer, node ast.Node, opts Options) error {
+	p := printer{w: bufio.NewWriter(w), opts: opts}
+	p.value(node, 0)
+
+	if err := p.w.Flush(); err != nil {
+		return err
+	}
+
+	return p.err
+}
+
+// String returns node written as JSON, Infinity and NaN as they are
+func String(node ast.Node, opts Options) string {
+	var b strings.Builder
+	Write(&b, node, opts)
+
+	return b.String()
+}
+
+type printer struct {
+	w    *bufio.Writer
+	opts Options
+	err  error // a number with no JSON form
+}
+
+func (p *printer) value(node ast.Node, depth int) {
+	switch node := node.(type) {
+	case *ast.Object:
+		p.object(node, depth)
+	case *ast.Array:
+		p.array(node, depth)
+	case *ast.String:
+		p.string(node.Value)
+	case *ast.Number:
+		p.number(node)
+	case *ast.Bool:
+		if node.Value {
+			p.w.WriteString("true")
+		} else {
+			p.w.WriteString("false")
+		}
+	case *ast.Null:
+		p.w.WriteString("null")
+	}
+}
+
+// number writes n as a JSON number, the JSON5 hexadecimal numbers in decimal,
+// without the leading '+' and with a digit on both sides of the '.'
+func (p *printer) number(n *ast.Number) {
+	lit := n.Literal
+	sign, unsigned := "", strings.TrimPrefix(lit, "+")
+	if strings.HasPrefix(unsigned, "-") {
+		sign, unsigned = "-", unsigned[1:]
+	}
+
+	switch {
+	case unsigned == "Infinity" || unsigned == "NaN":
+		p.w.WriteString(lit)
+		if p.err == nil {
+			p.err = ErrNonFinite
+		}
+		return
+
+	case strings.HasPrefix(unsigned, "0x") || strings.HasPrefix(unsigned, "0X"):
+		if i, err := n.BigInt(); err == nil {
+			p.w.WriteString(i.String())
+			return
+		}
+	}
+
+	mantissa, exp := unsigned, ""
+	if i := strings.IndexAny(unsigned, "eE"); i >= 0 {
+		mantissa, exp = unsigned[:i], unsigned[i:]
+	}
+	if strings.HasPrefix(mantissa, ".") {
+		mantissa = "0" + mantissa
+	}
+	mantissa = strings.TrimSuffix(mantissa, ".")
+
+	p.w.WriteString(sign + mantissa + exp)
+}
+
+func (p *printer) object(obj *ast.Object, depth int) {
+	if len(obj.Members) == 0 {
+		p.w.WriteString("{}")
+		return
+	}
+
+	members := obj.Members
+	if p.opts.SortKeys {
+		members = append([]*ast.Member(nil), members...)
+		sort.SliceStable(members, func(i, j int) bool {
+			return members[i].Key.Value < members[j].Key.Value
+		})
+	}
+
+	p.w.WriteByte('{')
+	for i, m := range members {
+		if i > 0 {
+			p.w.WriteByte(',')
+		}
+		p.newline(depth + 1)
+
+		p.string(m.Key.Value)
+		p.w.WriteByte(':')
+		if p.opts.Indent != "" {
+			p.w.WriteByte(' ')
+		}
+		p.value(m.Value, depth+1)
+	}
+	p.newline(depth)
+	p.w.WriteByte('}')
+}
+
+func (p *printer) array(arr *ast.Array, depth int) {
+	if len(arr.Elements) == 0 {
+		p.w.WriteString("[]")
+		return
+	}
+
+	p.w.WriteByte('[')
+	for i, elem := range arr.Elements {
+		if i > 0 {
+			p.w.WriteByte(',')
+		}
+		p.newline(depth + 1)
+		p.value(elem, depth+1)
+	}
+	p.newline(depth)
+	p.w.WriteByte(']')
+}
+
+// newline starts a line indented depth times, when indenting
+func (p *printer) newline(depth int) {
+	if p.opts.Indent == "" {
+		return
+	}
+
+	p.w.WriteByte('\n')
+	for i := 0; i < depth; i++ {
+		p.w.WriteString(p.opts.Indent)
+	}
+}
+
+// string writes s quoted, the quote, the backslash and the control
+// characters are escaped, the rest is written as is
+func (p *printer) string(s string) {
+	const hex = "0123456789abcdef"
+
+	p.w.WriteByte('"')
+
+	start := 0
+	for i := 0; i < len(s); {
+		ch := s[i]
+		if ch >= utf8.RuneSelf || ch >= ' ' && ch != '"' && ch != '\\' {
+			i++
+			continue
+		}
+
+		p.w.WriteString(s[start:i])
+
+		switch ch {
+		case '"', '\\':
+			p.w.WriteByte('\\')
+			p.w.WriteByte(ch)
+		case '\b':
+			p.w.WriteString(`\b`)
+		case '\f':
+			p.w.WriteString(`\f`)
+		case '\n':
+			p.w.WriteString(`\n`)
+		case '\r':
+			p.w.WriteString(`\r`)
+		case '\t':
+			p.w.WriteString(`\t`)
+		default:
+			p.w.WriteString(`\u00`)
+			p.w.WriteByte(hex[ch>>4])
+			p.w.WriteByte(hex[ch&0xf])
+		}
+
+		i++
+		start = i
+	}
+	p.w.WriteString(s[start:])
+
+	p.w.WriteByte('"')
+}
@@ -0,0 +1,224 @@
+package format
+
+import (
+	"encoding/json"
+	"jp/ast"
+	"jp/parser"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_String(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		opts Options
+		want string
+	}{
+		{
+			name: "minified",
+			src:  "{ \"a\" : [ 1 , 2.50 , -0e+3 ] ,\n \"b\" : { } , \"c\" : [ ] , \"d\" : null }",
+			want: `{"a":[1,2.50,-0e+3],"b":{},"c":[],"d":null}`,
+		},
+		{
+			name: "indented",
+			src:  `{"a": [1, {"b": true}], "c": {}}`,
+			opts: Options{Indent: "  "},
+			want: "{\n  \"a\": [\n    1,\n    {\n      \"b\": true\n    }\n  ],\n  \"c\": {}\n}",
+		},
+		{
+			name: "tabs",
+			src:  `[false]`,
+			opts: Options{Indent: "\t"},
+			want: "[\n\tfalse\n]",
+		},
+		{
+			name: "sorted keys",
+			src:  `{"b": 1, "a": {"d": 2, "c": 3}, "b": 4}`,
+			opts: Options{SortKeys: true},
+			want: `{"a":{"c":3,"d":2},"b":1,"b":4}`,
+		},
+		{
+			name: "escaped strings",
+			src:  `["quote \" backslash \\ slash \/ controls \b\f\n\r\t\u0001\u001f", "é 中 😀 <&>"]`,
+			want: `["quote \" backslash \\ slash / controls \b\f\n\r\t\u0001\u001f","é 中 😀 <&>"]`,
+		},
+		{
+			name: "escaped keys",
+			src:  `{"a\"b\n": 1}`,
+			want: `{"a\"b\n":1}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node, err := parser.Parse(tt.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := String(node, tt.opts); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// Test_RoundTrip checks that parsing the output gives back the tree of the
+// source, compared minified
+func Test_RoundTrip(t *testing.T) {
+	files, err := filepath.Glob("../test/pass*.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	steps, err := filepath.Glob("../tests/step*/valid*.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	options := []Options{
+		{},
+		{Indent: "  "},
+		{Indent: "\t", SortKeys: true},
+		{SortKeys: true},
+	}
+
+	for _, file := range append(files, steps...) {
+		t.Run(filepath.Base(filepath.Dir(file))+"/"+filepath.Base(file), func(t *testing.T) {
+			buf, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			node, err := parser.Parse(string(buf))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var want any
+			if err := json.Unmarshal(buf, &want); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, opts := range options {
+				out := String(node, opts)
+
+				// encoding/json agrees on the value
+				var value any
+				if err := json.Unmarshal([]byte(out), &value); err != nil || !reflect.DeepEqual(value, want) {
+					t.Errorf("String() with %+v = %q, json.Unmarshal() = %v, %v", opts, out, value, err)
+				}
+
+				got, err := parser.Parse(out)
+				if err != nil {
+					t.Fatalf("Parse() of %q error = %v", out, err)
+				}
+
+				if minified(got, opts) != minified(node, opts) {
+					t.Errorf("String() with %+v = %q, does not round trip", opts, out)
+				}
+			}
+		})
+	}
+}
+
+func minified(node ast.Node, opts Options) string {
+	return String(node, Options{SortKeys: opts.SortKeys})
+}
+
+func Test_WriteJSON5(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		want    string
+		wantErr error
+	}{
+		{
+			name: "hexadecimal",
+			src:  `[0xdecaf, -0XFF, +0x10]`,
+			want: `[912559,-255,16]`,
+		},
+		{
+			name: "decimal points",
+			src:  `[.5, -.5, 5., 5.e3, +.5E-2]`,
+			want: `[0.5,-0.5,5,5e3,0.5E-2]`,
+		},
+		{
+			name: "plus sign",
+			src:  `[+1, +1.5e+3]`,
+			want: `[1,1.5e+3]`,
+		},
+		{
+			name:    "infinity",
+			src:     `{a: Infinity, b: -Infinity}`,
+			want:    `{"a":Infinity,"b":-Infinity}`,
+			wantErr: ErrNonFinite,
+		},
+		{
+			name:    "nan",
+			src:     `[NaN]`,
+			want:    `[NaN]`,
+			wantErr: ErrNonFinite,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node, err := parser.ParseWithOptions(tt.src, parser.ParseOptions{Mode: parser.JSON5})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var b strings.Builder
+			err = Write(&b, node, Options{})
+			if got := b.String(); got != tt.want || err != tt.wantErr {
+				t.Errorf("Write() = %q, %v, want %q, %v", got, err, tt.want, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			got, err := parser.Parse(b.String())
+			if err != nil {
+				t.Fatalf("Parse() of %q error = %v", b.String(), err)
+			}
+			if !ast.Equal(got, node) {
+				t.Errorf("Write() = %q, does not round trip", b.String())
+			}
+		})
+	}
+
+	t.Run("test files", func(t *testing.T) {
+		files, err := filepath.Glob("../test/json5/pass*.json5")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, file := range files {
+			buf, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			node, err := parser.ParseWithOptions(string(buf), parser.ParseOptions{Mode: parser.JSON5})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var b strings.Builder
+			if err := Write(&b, node, Options{Indent: "  "}); err == ErrNonFinite {
+				continue
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := parser.Parse(b.String())
+			if err != nil {
+				t.Errorf("%s: Parse() of the output error = %v", file, err)
+			} else if !ast.Equal(got, node) {
+				t.Errorf("%s: Write() = %q, does not round trip", file, b.String())
+			}
+		}
+	})
+}
@@ -1,365 +1,681 @@
 package lexer
 
 import (
+	"bufio"
+	"fmt"
+	"io"
 	"jp/token"
-	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
 )
 
-type control struct {
-	value int
-}
-
-func (c *control) inc() {
-	c.value++
-}
-
-func (c *control) dec() {
-	c.value--
-	if c.value < 0 {
-		c.value = 0
-	}
-}
+// bufSize is the size of the read buffer, tokens can be longer
+const bufSize = 64 * 1024
 
-func (c *control) close() {
-	c.value = 0
+// Lexer splits a JSON document in tokens, the grammar is left to the parser.
+// The input is read through a fixed size buffer, so a document of any size
+// can be tokenized holding only the literal of the current token.
+type Lexer struct {
+	// MaxStringLen, when positive, caps the literal of the strings: a longer
+	// string is read to its end but its literal is cut after MaxStringLen+1
+	// bytes, enough to tell it is too long without holding all of it
+	MaxStringLen int
+
+	// Lines makes the newlines NEWLINE tokens instead of white space, for
+	// newline delimited JSON
+	Lines bool
+
+	// Comments makes the // and /* */ comments white space, for JSONC
+	Comments bool
+
+	// JSON5 accepts the JSON5 tokens on top of the JSON ones: single quoted
+	// strings and the JSON5 escape sequences, identifiers, hexadecimal
+	// numbers, Infinity, NaN, and numbers with a leading + or a leading or
+	// trailing decimal point. \v and \f are white space too.
+	JSON5 bool
+
+	r   *bufio.Reader
+	err error // read error, io.EOF excluded
+
+	ch     byte // current char under examination
+	eof    bool // the input is consumed, ch is not a char
+	offset int  // offset of ch
+	line   int  // line of ch, starting at 1
+	column int  // column of ch, starting at 1
+
+	lit     []byte   // literal of the token being read
+	problem *Problem // why the last token is ILLEGAL
 }
 
-func (c *control) isOpen() bool {
-	return c.value > 0
+// Problem tells why a token is ILLEGAL, when the lexer knows better than
+// the text of the token
+type Problem struct {
+	Pos token.Position // position of the offending text
+	Len int            // length of the offending text, in bytes
+	Msg string
 }
 
-type Lexer struct {
-	input        string
-	position     int  // current position in input (points to current char)
-	readPosition int  // current reading position in input (after current char)
-	ch           byte // current char under examination
-
-	expectKey    bool
-	expectValue  bool
-	expectObject bool
-	expectArray  bool
-	expectColon  bool
-	expectComma  bool
-
-	openObject *control
-	openArray  *control
-	openComma  *control
+func New(input string) *Lexer {
+	return NewReader(strings.NewReader(input))
 }
 
-func New(input string) *Lexer {
+// NewReader returns a lexer reading the document from r
+func NewReader(r io.Reader) *Lexer {
 	l := Lexer{
-		input: input,
-
-		expectObject: true,
-		expectArray:  true,
-
-		openObject: new(control),
-		openArray:  new(control),
-		openComma:  new(control),
+		r:      bufio.NewReaderSize(r, bufSize),
+		offset: -1,
+		line:   1,
 	}
 	l.readChar()
 
 	return &l
 }
 
+// NextToken returns the next token of the input, an EOF token once it is
+// consumed or can not be read any more, and an ILLEGAL token for anything
+// that is not a JSON token
 func (l *Lexer) NextToken() token.Token {
-	l.skipWhitespace()
-	tok := newToken(token.ILLEGAL, l.ch)
+	l.lit = l.lit[:0]
+	l.problem = nil
 
-	switch l.ch {
-	case ':':
-		if l.expectColon && !l.openComma.isOpen() {
-			tok = newToken(token.COLON, l.ch)
-			l.expect(false, true, true, true, false, false)
-		}
+	if !l.skipWhitespace() {
+		return token.Token{Type: token.ILLEGAL, Literal: "/*", Pos: l.problem.Pos}
+	}
+	pos := l.pos()
 
-	case '[':
-		if l.expectArray {
-			if l.openArray.value == 19 {
-				// too deep
-				return tok
-			}
+	if l.eof {
+		return token.Token{Type: token.EOF, Pos: pos}
+	}
 
-			tok = newToken(token.LBRACKET, l.ch)
-			l.expect(false, true, true, true, false, false)
-			l.openArray.inc()
-			l.openComma.close()
-		}
+	var tok token.Token
 
+	switch l.ch {
+	case ':':
+		tok = l.newToken(token.COLON)
+	case ',':
+		tok = l.newToken(token.COMMA)
+	case '[':
+		tok = l.newToken(token.LBRACKET)
 	case ']':
-		if l.openArray.isOpen() && !l.openComma.isOpen() {
-			tok = newToken(token.RBRACKET, l.ch)
-			l.openArray.dec()
-			l.expectComma = l.openArray.isOpen()
-		}
-
+		tok = l.newToken(token.RBRACKET)
 	case '{':
-		if l.expectObject {
-			tok = newToken(token.LBRACE, l.ch)
-			l.expect(true, false, false, false, false, false)
-			l.openObject.inc()
-			l.openComma.close()
-		}
-
+		tok = l.newToken(token.LBRACE)
 	case '}':
-		if l.openObject.isOpen() && !l.openComma.isOpen() {
-			tok = newToken(token.RBRACE, l.ch)
-			l.openObject.dec()
-			l.expectKey = l.openObject.isOpen()
-			l.expectComma = l.openObject.isOpen() || l.openArray.isOpen()
-		}
-
-	case ',':
-		if l.expectComma {
-			tok = newToken(token.COMMA, l.ch)
-			l.expect(l.openObject.isOpen(), l.openArray.isOpen(), l.openArray.isOpen(), l.openArray.isOpen(), false, false)
-			l.openComma.inc()
-		}
+		tok = l.newToken(token.RBRACE)
+	case '\n':
+		tok = l.newToken(token.NEWLINE)
 
-	case 0:
-		tok.Literal = ""
-		tok.Type = token.EOF
+	case '"':
+		tok = l.readString('"')
 
 	default:
-		if l.expectKey {
-			return l.readKey(tok)
-		}
-		if l.expectValue {
-			return l.readValue(tok)
+		switch {
+		case l.ch == '\'' && l.JSON5:
+			tok = l.readString('\'')
+		case isDigit(l.ch) || l.ch == '-' || l.JSON5 && (l.ch == '+' || l.ch == '.'):
+			tok = l.readNumber()
+		case isLetter(l.ch) || l.JSON5 && isIdentChar(l.ch):
+			tok = l.readKeyword()
+		default:
+			tok = l.illegalChar()
 		}
 	}
 
-	l.readChar()
+	tok.Pos = pos
 	return tok
 }
 
+// Offset returns the offset of the byte following the last token
+func (l *Lexer) Offset() int {
+	return l.offset
+}
+
+// Problem returns why the last token is ILLEGAL, or nil when it is just
+// not a JSON token
+func (l *Lexer) Problem() *Problem {
+	return l.problem
+}
+
+// Err returns the error that stopped the reading of the input, if any
+func (l *Lexer) Err() error {
+	return l.err
+}
+
 func (l *Lexer) readChar() {
-	if l.readPosition >= len(l.input) {
-		l.ch = 0
-	} else {
-		l.ch = l.input[l.readPosition]
+	if l.eof {
+		return
 	}
 
-	l.position = l.readPosition
-	l.readPosition += 1
-}
+	if l.ch == '\n' {
+		l.line++
+		l.column = 0
+	}
 
-func (l *Lexer) skipWhitespace() {
-	for l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r' {
-		l.readChar()
+	l.offset++
+	l.column++
+
+	ch, err := l.r.ReadByte()
+	if err != nil {
+		l.ch = 0
+		l.eof = true
+		if err != io.EOF {
+			l.err = err
+		}
+		return
 	}
+
+	l.ch = ch
 }
 
-func (l *Lexer) expect(key, value, object, array, colon, comma bool) {
-	l.expectKey = key
-	l.expectValue = value
-	l.expectObject = object
-	l.expectArray = array
-	l.expectColon = colon
-	l.expectComma = comma
+// pos returns the position of the current char
+func (l *Lexer) pos() token.Position {
+	return token.Position{Offset: l.offset, Line: l.line, Column: l.column}
 }
 
-func (l *Lexer) readKey(tok token.Token) token.Token {
-	if !isQuote(l.ch) {
-		return tok
-	}
+// skipWhitespace skips the white space and the comments, it returns false
+// on an unterminated comment
+func (l *Lexer) skipWhitespace() bool {
+	for {
+		switch {
+		case l.ch == ' ' || l.ch == '\t' || l.ch == '\n' && !l.Lines || l.ch == '\r':
+			l.readChar()
 
-	l.readChar()
-	position := l.position
+		case l.JSON5 && (l.ch == '\v' || l.ch == '\f'):
+			l.readChar()
 
-	for {
-		if isQuote(l.ch) {
-			break
-		}
+		case l.Comments && l.ch == '/' && l.peekIs('/'):
+			// a line comment ends before the newline, a NEWLINE token in
+			// Lines mode
+			for !l.eof && l.ch != '\n' {
+				l.readChar()
+			}
 
-		if l.ch == 0 {
-			return tok
+		case l.Comments && l.ch == '/' && l.peekIs('*'):
+			if !l.skipBlockComment() {
+				return false
+			}
+
+		default:
+			return true
 		}
+	}
+}
 
+// skipBlockComment skips a /* */ comment, it returns false when it is
+// unterminated
+func (l *Lexer) skipBlockComment() bool {
+	start := l.pos()
+	l.readChar()
+	l.readChar()
+
+	for !l.eof {
+		if l.ch == '*' && l.peekIs('/') {
+			l.readChar()
+			l.readChar()
+			return true
+		}
 		l.readChar()
 	}
 
-	tok.Type = token.KEY
-	tok.Literal = l.input[position:l.position]
+	l.problem = &Problem{Pos: start, Len: 2, Msg: "unterminated comment"}
+
+	return false
+}
+
+// peekIs reports whether the char following the current one is one of
+// chars
+func (l *Lexer) peekIs(chars ...byte) bool {
+	next, _ := l.r.Peek(1)
+	if len(next) == 0 {
+		return false
+	}
 
-	if position == l.position {
-		tok.Literal = "EMPTY"
+	for _, ch := range chars {
+		if next[0] == ch {
+			return true
+		}
 	}
 
+	return false
+}
+
+// readString reads a string quoted with quote, the literal is its decoded
+// content: escape sequences are replaced by the characters they stand for,
+// and unpaired surrogates and invalid UTF-8 bytes by U+FFFD, like
+// encoding/json does.
+func (l *Lexer) readString(quote byte) token.Token {
+	start := l.pos()
 	l.readChar()
-	l.expect(false, false, false, false, true, false)
-	l.openComma.close()
 
-	return tok
+	for l.ch != quote {
+		switch {
+		case l.eof:
+			l.problem = &Problem{Pos: start, Len: l.offset - start.Offset, Msg: "unterminated string"}
+			return l.illegalString(quote)
+
+		case l.ch == '\\':
+			pos := l.pos()
+			r, seq, ok := l.readEscape()
+			if !ok {
+				l.problem = &Problem{Pos: pos, Len: len(seq), Msg: invalidEscape(seq)}
+				return l.illegalString(quote)
+			}
+
+			// a line continuation stands for nothing
+			if r >= 0 {
+				for _, b := range utf8.AppendRune(nil, r) {
+					l.appendString(b)
+				}
+			}
+			continue
+
+		case l.ch < ' ' && (!l.JSON5 || l.ch == '\n' || l.ch == '\r'):
+			// control characters must be escaped, JSON5 only requires it
+			// for the line terminators
+			l.problem = &Problem{Pos: l.pos(), Len: 1, Msg: fmt.Sprintf("invalid character %q in string", l.ch)}
+			return l.illegalString(quote)
+		}
+
+		l.appendString(l.ch)
+		l.readChar()
+	}
+	l.readChar()
+
+	return token.Token{Type: token.STRING, Literal: validUTF8(string(l.lit))}
 }
 
-func (l *Lexer) readValue(tok token.Token) token.Token {
-	if isDigit(l.ch) {
-		return l.getValueNumber(tok)
+// appendString appends b to the literal of a string, up to MaxStringLen+1
+// bytes
+func (l *Lexer) appendString(b byte) {
+	if l.MaxStringLen <= 0 || len(l.lit) <= l.MaxStringLen {
+		l.lit = append(l.lit, b)
 	}
+}
 
-	if isQuote(l.ch) {
-		return l.getValueString(tok)
+// illegalString returns the token of a malformed string, its literal is
+// what was read until the offending char, quote included. The rest of the
+// string is skipped, up to the closing quote or the end of the line, so
+// lexing can go on after it.
+func (l *Lexer) illegalString(quote byte) token.Token {
+	tok := token.Token{Type: token.ILLEGAL, Literal: string(quote) + string(l.lit)}
+
+	for !l.eof && l.ch != quote && l.ch != '\n' {
+		if l.ch == '\\' {
+			l.readChar()
+		}
+		if !l.eof && l.ch != '\n' {
+			l.readChar()
+		}
+	}
+	if l.ch == quote {
+		l.readChar()
 	}
 
-	return l.getValueSpecial(tok)
+	return tok
 }
 
-func (l *Lexer) getValueNumber(tok token.Token) token.Token {
-	position := l.position
-	initialElement := l.ch
-	hasMinus := false
-	hasDot := false
-	hasE := false
-	hasEMinus := false
-	hasEPlus := false
-
-	if l.ch == 'e' || l.ch == 'E' || l.ch == '+' {
-		return tok
+// readEscape reads the escape sequence starting at the current backslash
+// and returns the character it stands for, -1 for a JSON5 line
+// continuation. A \uXXXX high surrogate is combined with the \uXXXX low
+// surrogate that follows it. When the sequence is invalid nothing is read
+// and seq holds its text, as far as it looks like one.
+func (l *Lexer) readEscape() (r rune, seq string, ok bool) {
+	// the char after the backslash, and a \uXXXX\uXXXX pair at most
+	next, _ := l.r.Peek(11)
+	if len(next) == 0 {
+		return 0, `\`, false
 	}
 
-	for {
-		if !isDigit(l.ch) {
-			if position == l.position {
-				return tok
+	size := 2
+
+	switch next[0] {
+	case '"', '\\', '/':
+		r = rune(next[0])
+	case 'b':
+		r = '\b'
+	case 'f':
+		r = '\f'
+	case 'n':
+		r = '\n'
+	case 'r':
+		r = '\r'
+	case 't':
+		r = '\t'
+
+	case 'u':
+		r, ok = hex4(next[1:])
+		if !ok {
+			n := 1
+			for n < min(5, len(next)) && unhex(next[n]) >= 0 {
+				n++
 			}
+			return 0, `\` + string(next[:n]), false
+		}
+		size = 6
 
-			last := l.input[l.position]
-			if last == 'e' || last == '+' || last == '-' {
-				return tok
+		if utf16.IsSurrogate(r) {
+			low, ok := hex4(next[min(7, len(next)):])
+			isPair := ok && next[5] == '\\' && next[6] == 'u'
+
+			if dec := utf16.DecodeRune(r, low); isPair && dec != utf8.RuneError {
+				r = dec
+				size += 6
+			} else {
+				r = utf8.RuneError
 			}
-			if len(l.input[position:l.position]) > 1 && initialElement == '0' && (!hasE || !hasDot) {
-				return tok
+		}
+
+	default:
+		if !l.JSON5 {
+			_, n := utf8.DecodeRune(next)
+			return 0, `\` + string(next[:n]), false
+		}
+
+		r, size, ok = json5Escape(next)
+		if !ok {
+			n := 1
+			for next[0] == 'x' && n < min(3, len(next)) && unhex(next[n]) >= 0 {
+				n++
 			}
+			return 0, `\` + string(next[:n]), false
+		}
+	}
 
-			tok.Type = token.VALUE
-			tok.Literal = l.input[position:l.position]
+	for i := 0; i < size; i++ {
+		l.readChar()
+	}
 
-			l.expect(false, false, false, false, false, true)
+	return r, "", true
+}
 
-			break
-		}
+// invalidEscape returns the message of the invalid escape sequence seq. A
+// character after the backslash that is not printable, like a newline, is
+// quoted alone.
+func invalidEscape(seq string) string {
+	if r, n := utf8.DecodeRuneInString(seq[1:]); n > 0 && n == len(seq)-1 && !strconv.IsPrint(r) {
+		return fmt.Sprintf("invalid character %q after '\\' in string", r)
+	}
 
-		if l.ch == '-' {
-			if hasMinus {
-				return tok
-			}
+	return fmt.Sprintf("invalid escape sequence '%s' in string", seq)
+}
 
-			hasMinus = true
-		}
+// json5Escape decodes the escape sequences JSON5 adds to JSON, next holds
+// what follows the backslash. Any character but a digit stands for itself.
+func json5Escape(next []byte) (r rune, size int, ok bool) {
+	switch ch := next[0]; {
+	case ch == 'v':
+		return '\v', 2, true
 
-		if l.ch == '.' {
-			if hasDot {
-				return tok
-			}
+	case ch == '0':
+		if len(next) > 1 && '0' <= next[1] && next[1] <= '9' {
+			return 0, 0, false
+		}
+		return 0, 2, true
 
-			hasDot = true
+	case ch == 'x':
+		if len(next) < 3 || unhex(next[1]) < 0 || unhex(next[2]) < 0 {
+			return 0, 0, false
 		}
+		return unhex(next[1])<<4 | unhex(next[2]), 4, true
 
-		if l.ch == 'e' || l.ch == 'E' {
-			if hasE {
-				return tok
-			}
+	case ch == '\n':
+		return -1, 2, true
 
-			hasE = true
+	case ch == '\r':
+		if len(next) > 1 && next[1] == '\n' {
+			return -1, 3, true
 		}
+		return -1, 2, true
+
+	case '1' <= ch && ch <= '9':
+		return 0, 0, false
+	}
+
+	r, n := utf8.DecodeRune(next)
+	if r == '\u2028' || r == '\u2029' {
+		return -1, 1 + n, true
+	}
 
-		if position > l.position && l.ch == '-' {
-			if hasEMinus {
-				return tok
+	return r, 1 + n, true
+}
+
+// readNumber reads a number
+func (l *Lexer) readNumber() token.Token {
+	if l.ch == '-' || l.JSON5 && l.ch == '+' {
+		l.appendChar()
+	}
+
+	if l.JSON5 {
+		switch {
+		case l.ch == 'I' || l.ch == 'N':
+			// a signed Infinity or NaN
+			for isLetter(l.ch) {
+				l.appendChar()
+			}
+			if lit := string(l.lit[1:]); lit == "Infinity" || lit == "NaN" {
+				return token.Token{Type: token.NUMBER, Literal: string(l.lit)}
 			}
+			return l.illegal()
 
-			hasEMinus = true
+		case l.ch == '0' && l.peekIs('x', 'X'):
+			return l.readHex()
 		}
+	}
 
-		if position > l.position && l.ch == '+' {
-			if hasEPlus {
-				return tok
-			}
+	intDigits := l.readDigits()
+	if intDigits > 1 && l.lit[len(l.lit)-intDigits] == '0' {
+		// leading zero
+		return l.illegal()
+	}
+
+	fracDigits := -1
+	if l.ch == '.' {
+		l.appendChar()
+		fracDigits = l.readDigits()
+	}
+
+	// JSON5 allows .5 and 5.
+	if intDigits == 0 && (!l.JSON5 || fracDigits <= 0) || fracDigits == 0 && !l.JSON5 {
+		return l.illegal()
+	}
 
-			hasEPlus = true
+	if l.ch == 'e' || l.ch == 'E' {
+		l.appendChar()
+		if l.ch == '+' || l.ch == '-' {
+			l.appendChar()
 		}
+		if l.readDigits() == 0 {
+			return l.illegal()
+		}
+	}
 
-		l.readChar()
+	// like 1.2.3, 1e2e3 or 12ab
+	if isNumberChar(l.ch) {
+		return l.illegal()
 	}
 
-	return tok
+	return token.Token{Type: token.NUMBER, Literal: string(l.lit)}
 }
 
-func (l *Lexer) getValueString(tok token.Token) token.Token {
-	l.readChar()
-	position := l.position
+// readHex reads a hexadecimal number of JSON5, after its sign
+func (l *Lexer) readHex() token.Token {
+	l.appendChar()
+	l.appendChar()
 
-LOOP:
-	for {
-		switch l.ch {
-		case '"':
-			break LOOP
+	n := 0
+	for unhex(l.ch) >= 0 {
+		l.appendChar()
+		n++
+	}
+
+	if n == 0 || isNumberChar(l.ch) {
+		return l.illegal()
+	}
+
+	return token.Token{Type: token.NUMBER, Literal: string(l.lit)}
+}
 
-		case '\\':
-			return tok
+// readDigits reads decimal digits and returns how many
+func (l *Lexer) readDigits() int {
+	n := 0
+	for isDigit(l.ch) {
+		l.appendChar()
+		n++
+	}
+
+	return n
+}
 
-		case '\t':
-			return tok
+// appendChar appends the current char to the literal and moves past it
+func (l *Lexer) appendChar() {
+	l.lit = append(l.lit, l.ch)
+	l.readChar()
+}
 
-		case '\n':
-			return tok
+// readKeyword reads true, false or null, and in JSON5 Infinity, NaN and
+// identifiers
+func (l *Lexer) readKeyword() token.Token {
+	for isLetter(l.ch) || '0' <= l.ch && l.ch <= '9' || l.JSON5 && isIdentChar(l.ch) {
+		l.appendChar()
+	}
 
-		case '\r':
-			return tok
+	literal := string(l.lit)
+
+	switch literal {
+	case "true":
+		return token.Token{Type: token.TRUE, Literal: literal}
+	case "false":
+		return token.Token{Type: token.FALSE, Literal: literal}
+	case "null":
+		return token.Token{Type: token.NULL, Literal: literal}
+	}
+
+	if l.JSON5 {
+		switch {
+		case literal == "Infinity" || literal == "NaN":
+			return token.Token{Type: token.NUMBER, Literal: literal}
+		case isIdentifier(literal):
+			return token.Token{Type: token.IDENT, Literal: literal}
 		}
+	}
 
-		l.readChar()
+	return token.Token{Type: token.ILLEGAL, Literal: literal}
+}
+
+// illegal skips the rest of a malformed token
+func (l *Lexer) illegal() token.Token {
+	for isNumberChar(l.ch) {
+		l.appendChar()
 	}
 
-	if position == l.position {
-		return tok
+	return token.Token{Type: token.ILLEGAL, Literal: string(l.lit)}
+}
+
+// illegalChar returns the ILLEGAL token of the current char, the whole rune
+// when it starts a multi-byte UTF-8 character, like é or a smart quote
+func (l *Lexer) illegalChar() token.Token {
+	l.appendChar()
+	for !utf8.FullRune(l.lit) && !l.eof && !utf8.RuneStart(l.ch) {
+		l.appendChar()
 	}
 
-	tok.Type = token.VALUE
-	tok.Literal = l.input[position:l.position]
+	return token.Token{Type: token.ILLEGAL, Literal: string(l.lit)}
+}
 
+// newToken returns the token of the current char and moves past it
+func (l *Lexer) newToken(tokenType token.TokenType) token.Token {
+	tok := token.Token{
+		Type:    tokenType,
+		Literal: string(l.ch),
+	}
 	l.readChar()
-	l.expect(false, false, false, false, false, true)
 
 	return tok
 }
 
-func (l *Lexer) getValueSpecial(tok token.Token) token.Token {
-	size := 4
-	special := l.input[l.position : l.position+size]
-	ok := slices.Contains([]string{"null", "true"}, special)
+func isDigit(ch byte) bool {
+	return '0' <= ch && ch <= '9'
+}
 
-	if !ok {
-		size = 5
-		special = l.input[l.position : l.position+size]
-		ok = special == "false"
-	}
+// isNumberChar reports whether ch is part of a malformed number, like the
+// rest of 1.2.3, 1e2e3, 12ab or 1-2
+func isNumberChar(ch byte) bool {
+	return isDigit(ch) || isLetter(ch) || ch == '.' || ch == '-' || ch == '+'
+}
 
-	if ok {
-		tok.Type = token.VALUE
-		tok.Literal = l.input[l.position : l.position+size]
+func isLetter(ch byte) bool {
+	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_'
+}
 
-		for i := 0; i < size; i++ {
-			l.readChar()
+// isIdentChar reports whether ch can be part of a JSON5 identifier on top
+// of the letters and the digits, a non ASCII byte is checked by
+// isIdentifier
+func isIdentChar(ch byte) bool {
+	return ch == '$' || ch >= utf8.RuneSelf
+}
+
+// isIdentifier reports whether s is a JSON5 identifier: Unicode letters,
+// digits, _ and $, not starting with a digit
+func isIdentifier(s string) bool {
+	for i, r := range s {
+		switch {
+		case r == utf8.RuneError:
+			return false
+		case unicode.IsLetter(r) || r == '_' || r == '$':
+		case i > 0 && (unicode.IsDigit(r) || unicode.In(r, unicode.Mn, unicode.Mc)):
+		default:
+			return false
 		}
-		l.expect(false, false, false, false, false, true)
 	}
 
-	return tok
+	return s != ""
 }
 
-func newToken(tokenType token.TokenType, ch byte) token.Token {
-	return token.Token{
-		Type:    tokenType,
-		Literal: string(ch),
+// unhex returns the value of a hexadecimal digit, -1 for any other char
+func unhex(ch byte) rune {
+	switch {
+	case '0' <= ch && ch <= '9':
+		return rune(ch - '0')
+	case 'a' <= ch && ch <= 'f':
+		return rune(ch - 'a' + 10)
+	case 'A' <= ch && ch <= 'F':
+		return rune(ch - 'A' + 10)
 	}
+
+	return -1
 }
 
-func isQuote(ch byte) bool {
-	return ch == '"'
+// hex4 decodes the 4 hexadecimal digits at the start of b
+func hex4(b []byte) (rune, bool) {
+	if len(b) < 4 {
+		return 0, false
+	}
+
+	var r rune
+	for _, ch := range b[:4] {
+		n := unhex(ch)
+		if n < 0 {
+			return 0, false
+		}
+		r = r<<4 | n
+	}
+
+	return r, true
 }
 
-func isDigit(ch byte) bool {
-	return '0' <= ch && ch <= '9' || ch == '.' || ch == '-' || ch == '+' || ch == 'e' || ch == 'E'
+// validUTF8 replaces every invalid UTF-8 byte of s by U+FFFD
+func validUTF8(s string) string {
+	if utf8.ValidString(s) {
+		return s
+	}
+
+	buf := make([]byte, 0, len(s))
+	for _, r := range s {
+		buf = utf8.AppendRune(buf, r)
+	}
+
+	return string(buf)
 }
@@ -1,27 +1,599 @@
 package main
 
 import (
+	"bytes"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
+	"jp/ast"
+	"jp/format"
 	"jp/parser"
+	"jp/patch"
+	"jp/schema"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
 )
 
+// snippetRadius is the number of bytes shown on each side of an error, for
+// the files made of very long lines
+const snippetRadius = 60
+
+const usage = `invalid usage, call one of
+  jp [-ndjson | -all] <filename.json>                      validate the file
+  jp fmt [-indent N] [-tab] [-sort] [-w] <filename.json>   indent the file
+  jp min [-w] <filename.json>                              minify the file
+  jp query [-min] <expression> <filename.json>             print the values selected by a JSONPath expression
+  jp validate -schema <schema.json> <filename.json>        validate the file against a JSON Schema
+  jp diff [-patch] <a.json> <b.json>                       print the changes from a to b, or them as a JSON Patch
+  jp patch [-merge] [-w] <filename.json> <patch.json>      apply a JSON Patch, or a JSON Merge Patch
+
+every command takes -mode strict, jsonc or json5, the dialect of JSON of the file,
+the output is strict JSON without the comments, so -w needs -mode strict`
+
 func main() {
-	if len(os.Args) != 2 {
-		fmt.Println("invalid usage, call jp <filename.json>")
-		os.Exit(2)
+	args := os.Args[1:]
+
+	cmd := ""
+	if len(args) > 0 {
+		switch args[0] {
+		case "fmt", "min", "query", "validate", "diff", "patch":
+			cmd, args = args[0], args[1:]
+		}
+	}
+
+	switch cmd {
+	case "fmt", "min":
+		os.Exit(runFormat(cmd, args))
+	case "query":
+		os.Exit(runQuery(args))
+	case "validate":
+		os.Exit(runSchema(args))
+	case "diff":
+		os.Exit(runDiff(args))
+	case "patch":
+		os.Exit(runPatch(args))
+	default:
+		os.Exit(runValidate(args))
+	}
+}
+
+// runValidate validates a file, it returns the exit code
+func runValidate(args []string) int {
+	flags := newFlagSet("jp")
+	mode := modeFlag(flags)
+	ndjson := flags.Bool("ndjson", false, "validate newline delimited JSON, every line is a document")
+	all := flags.Bool("all", false, "report every syntax error, not just the first one")
+
+	file, ok := parseArgs(flags, args)
+	if !ok {
+		return 2
+	}
+	if *ndjson && *all {
+		flags.Usage()
+		return 2
 	}
 
-	buf, err := os.ReadFile(os.Args[1])
+	f, err := os.Open(file)
 	if err != nil {
-		fmt.Printf("failed to read file %q: %v", os.Args[1], err)
-		os.Exit(2)
+		fmt.Printf("failed to read file %q: %v", file, err)
+		return 2
 	}
+	defer f.Close()
+
+	valid := true
+	if *ndjson {
+		err = parser.ParseLines(f, parser.ParseOptions{Mode: *mode}, func(_ int, _ ast.Node, err error) error {
+			if err != nil {
+				printError(file, f, err)
+				valid = false
+			}
+			return nil
+		})
+	} else if *all {
+		_, err = parser.ParseRecover(f, parser.ParseOptions{Mode: *mode})
 
-	if !parser.IsValid(string(buf), true) {
+		var errs parser.ErrorList
+		if errors.As(err, &errs) {
+			for _, err := range errs {
+				printError(file, f, err)
+			}
+			valid, err = false, nil
+		}
+	} else {
+		err = parser.Validate(f, parser.ParseOptions{Mode: *mode})
+	}
+
+	if err != nil {
+		printError(file, f, err)
+		valid = false
+	}
+
+	if !valid {
 		fmt.Println("invalid json file")
-		os.Exit(1)
+		return 1
 	}
 
 	fmt.Println("valid json file")
+	return 0
+}
+
+// runFormat indents, or minifies for min, a file on stdout or in place, it
+// returns the exit code
+func runFormat(cmd string, args []string) int {
+	flags := newFlagSet("jp " + cmd)
+	mode := modeFlag(flags)
+	write := flags.Bool("w", false, "write the result to the file instead of stdout")
+
+	var indent *int
+	var tab, sortKeys *bool
+	if cmd == "fmt" {
+		indent = flags.Int("indent", 2, "number of spaces per nesting level")
+		tab = flags.Bool("tab", false, "indent with tabs")
+		sortKeys = flags.Bool("sort", false, "sort the members of the objects by key")
+	}
+
+	file, ok := parseArgs(flags, args)
+	if !ok {
+		return 2
+	}
+	if *write && *mode != parser.Strict {
+		fmt.Printf("-w would rewrite the %s file %q as strict JSON and drop its comments\n", *mode, file)
+		return 2
+	}
+
+	opts := format.Options{}
+	if cmd == "fmt" {
+		if *indent < 0 {
+			fmt.Println(usage)
+			return 2
+		}
+
+		opts.Indent = strings.Repeat(" ", *indent)
+		if *tab {
+			opts.Indent = "\t"
+		}
+		opts.SortKeys = *sortKeys
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		fmt.Printf("failed to read file %q: %v", file, err)
+		return 2
+	}
+	defer f.Close()
+
+	node, err := parser.ParseReader(f, parser.ParseOptions{Mode: *mode})
+	if err != nil {
+		printError(file, f, err)
+		fmt.Println("invalid json file")
+		return 1
+	}
+
+	var out bytes.Buffer
+	if err := format.Write(&out, node, opts); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", file, err)
+		fmt.Println("failed to format the file")
+		return 1
+	}
+	out.WriteByte('\n')
+
+	if !*write {
+		os.Stdout.Write(out.Bytes())
+		return 0
+	}
+
+	if err := writeFile(file, out.Bytes()); err != nil {
+		fmt.Printf("failed to write file %q: %v", file, err)
+		return 2
+	}
+
+	return 0
+}
+
+// runQuery prints the values of a file selected by a JSONPath expression,
+// as a JSON array, it returns the exit code
+func runQuery(args []string) int {
+	flags := newFlagSet("jp query")
+	mode := modeFlag(flags)
+	minify := flags.Bool("min", false, "print the values minified")
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return 2
+	}
+	expr, file := flags.Arg(0), flags.Arg(1)
+
+	f, err := os.Open(file)
+	if err != nil {
+		fmt.Printf("failed to read file %q: %v", file, err)
+		return 2
+	}
+	defer f.Close()
+
+	node, err := parser.ParseReader(f, parser.ParseOptions{Mode: *mode})
+	if err != nil {
+		printError(file, f, err)
+		fmt.Println("invalid json file")
+		return 1
+	}
+
+	nodes, err := parser.Query(node, expr)
+	if err != nil {
+		var queryErr *parser.QueryError
+		if errors.As(err, &queryErr) {
+			fmt.Fprintf(os.Stderr, "query: %v\n", err)
+			fmt.Fprintf(os.Stderr, " | %s\n", expr)
+			fmt.Fprintf(os.Stderr, " | %s^\n", strings.Repeat(" ", utf8.RuneCountInString(expr[:queryErr.Offset])))
+		}
+		fmt.Println("invalid query")
+		return 2
+	}
+
+	opts := format.Options{Indent: "  "}
+	if *minify {
+		opts.Indent = ""
+	}
+
+	err = format.Write(os.Stdout, &ast.Array{Elements: nodes}, opts)
+	fmt.Println()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", file, err)
+		return 1
+	}
+
+	return 0
+}
+
+// runSchema validates a file against a JSON Schema, it returns the exit
+// code
+func runSchema(args []string) int {
+	flags := newFlagSet("jp validate")
+	mode := modeFlag(flags)
+	schemaFile := flags.String("schema", "", "the JSON Schema file")
+
+	file, ok := parseArgs(flags, args)
+	if !ok {
+		return 2
+	}
+	if *schemaFile == "" {
+		flags.Usage()
+		return 2
+	}
+
+	s, ok := compileSchema(*schemaFile)
+	if !ok {
+		return 2
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		fmt.Printf("failed to read file %q: %v", file, err)
+		return 2
+	}
+	defer f.Close()
+
+	node, err := parser.ParseReader(f, parser.ParseOptions{Mode: *mode})
+	if err != nil {
+		printError(file, f, err)
+		fmt.Println("invalid json file")
+		return 1
+	}
+
+	violations := s.Validate(node)
+	for _, v := range violations {
+		fmt.Fprintf(os.Stderr, "%s:%v\n", file, v)
+	}
+
+	if len(violations) > 0 {
+		fmt.Println("json file does not match the schema")
+		return 1
+	}
+
+	fmt.Println("valid json file")
+	return 0
+}
+
+// runDiff prints the changes between two files, or the JSON Patch that
+// makes the first one the second, it returns the exit code: 1 when the files
+// differ, like diff
+func runDiff(args []string) int {
+	flags := newFlagSet("jp diff")
+	mode := modeFlag(flags)
+	asPatch := flags.Bool("patch", false, "print the changes as a JSON Patch")
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return 2
+	}
+
+	a, code := parseFile(flags.Arg(0), *mode)
+	if a == nil {
+		return code
+	}
+	b, code := parseFile(flags.Arg(1), *mode)
+	if b == nil {
+		return code
+	}
+
+	changes := patch.Diff(a, b)
+
+	if *asPatch {
+		err := format.Write(os.Stdout, patch.FromDiff(changes).Node(), format.Options{Indent: "  "})
+		fmt.Println()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "jp diff: %v\n", err)
+			return 2
+		}
+	} else {
+		for _, c := range changes {
+			fmt.Println(c)
+		}
+	}
+
+	if len(changes) > 0 {
+		return 1
+	}
+
+	return 0
+}
+
+// runPatch applies a JSON Patch, or a JSON Merge Patch, to a file and
+// prints the result or writes it in place, it returns the exit code. A
+// patch that is an array is a JSON Patch unless -merge is given.
+func runPatch(args []string) int {
+	flags := newFlagSet("jp patch")
+	mode := modeFlag(flags)
+	merge := flags.Bool("merge", false, "apply the patch as a JSON Merge Patch")
+	write := flags.Bool("w", false, "write the result to the file instead of stdout")
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return 2
+	}
+	file, patchFile := flags.Arg(0), flags.Arg(1)
+	if *write && *mode != parser.Strict {
+		fmt.Printf("-w would rewrite the %s file %q as strict JSON and drop its comments\n", *mode, file)
+		return 2
+	}
+
+	doc, code := parseFile(file, *mode)
+	if doc == nil {
+		return code
+	}
+	p, code := parseFile(patchFile, *mode)
+	if p == nil {
+		return code
+	}
+
+	var result ast.Node
+	if _, isArray := p.(*ast.Array); isArray && !*merge {
+		ops, err := patch.Parse(p)
+		if err == nil {
+			result, err = patch.Apply(doc, ops)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", patchFile, err)
+			fmt.Println("failed to apply the patch")
+			return 1
+		}
+	} else {
+		result = patch.MergePatch(doc, p)
+	}
+
+	var out bytes.Buffer
+	if err := format.Write(&out, result, format.Options{Indent: "  "}); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", file, err)
+		fmt.Println("failed to apply the patch")
+		return 1
+	}
+	out.WriteByte('\n')
+
+	if !*write {
+		os.Stdout.Write(out.Bytes())
+		return 0
+	}
+
+	if err := writeFile(file, out.Bytes()); err != nil {
+		fmt.Printf("failed to write file %q: %v", file, err)
+		return 2
+	}
+
+	return 0
+}
+
+// parseFile reads and parses a file, the errors are printed. The node is nil
+// on failure, with the exit code.
+func parseFile(file string, mode parser.Mode) (ast.Node, int) {
+	f, err := os.Open(file)
+	if err != nil {
+		fmt.Printf("failed to read file %q: %v", file, err)
+		return nil, 2
+	}
+	defer f.Close()
+
+	node, err := parser.ParseReader(f, parser.ParseOptions{Mode: mode})
+	if err != nil {
+		printError(file, f, err)
+		fmt.Println("invalid json file")
+		return nil, 1
+	}
+
+	return node, 0
+}
+
+// compileSchema reads and compiles a schema file, the errors are printed
+func compileSchema(file string) (*schema.Schema, bool) {
+	f, err := os.Open(file)
+	if err != nil {
+		fmt.Printf("failed to read file %q: %v", file, err)
+		return nil, false
+	}
+	defer f.Close()
+
+	node, err := parser.ParseReader(f, parser.ParseOptions{})
+	if err != nil {
+		printError(file, f, err)
+		fmt.Println("invalid schema file")
+		return nil, false
+	}
+
+	s, err := schema.Compile(node)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s:%v\n", file, err)
+		fmt.Println("invalid schema file")
+		return nil, false
+	}
+
+	return s, true
+}
+
+// modeValue is the -mode flag
+type modeValue parser.Mode
+
+func (m *modeValue) String() string {
+	return parser.Mode(*m).String()
+}
+
+func (m *modeValue) Set(s string) error {
+	for mode := parser.Strict; mode <= parser.JSON5; mode++ {
+		if s == mode.String() {
+			*m = modeValue(mode)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown mode %q", s)
+}
+
+// modeFlag defines the -mode flag of a command, strict by default
+func modeFlag(flags *flag.FlagSet) *parser.Mode {
+	mode := new(parser.Mode)
+	flags.Var((*modeValue)(mode), "mode", "dialect of JSON: strict, jsonc or json5")
+
+	return mode
+}
+
+func newFlagSet(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Println(usage)
+	}
+
+	return flags
+}
+
+// parseArgs parses the flags of a command followed by the file name
+func parseArgs(flags *flag.FlagSet, args []string) (string, bool) {
+	if err := flags.Parse(args); err != nil {
+		return "", false
+	}
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return "", false
+	}
+
+	return flags.Arg(0), true
+}
+
+// writeFile replaces the content of file with data, through a temporary
+// file renamed over it so the file is never left half written
+func writeFile(file string, data []byte) error {
+	info, err := os.Stat(file)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(file), filepath.Base(file)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(info.Mode().Perm()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), file)
+}
+
+// printError prints err on stderr, a syntax error is followed by its source
+// line with the offending text underlined:
+//
+//	config.json:3:3: unexpected 'key2', expected string key after ','
+//	 3 |   key2: "value"
+//	   |   ^^^^
+func printError(file string, src io.ReaderAt, err error) {
+	var syntaxErr *parser.SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", file, err)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "%s:%v\n", file, err)
+
+	// the line is read around the error, the column tells where it starts
+	pos := syntaxErr.Pos
+	lineStart := pos.Offset - (pos.Column - 1)
+	from := max(lineStart, pos.Offset-snippetRadius)
+
+	buf := make([]byte, pos.Offset-from+snippetRadius)
+	n, _ := src.ReadAt(buf, int64(from))
+	if n < pos.Offset-from {
+		return
+	}
+
+	before := string(buf[:pos.Offset-from])
+	after := string(buf[pos.Offset-from : n])
+
+	more := n == len(buf)
+	if i := strings.IndexByte(after, '\n'); i >= 0 {
+		after = after[:i]
+		more = false
+	}
+	after = strings.TrimRight(after, "\r")
+
+	prefix, suffix := "", ""
+	if from > lineStart {
+		prefix = "..."
+	}
+	if more {
+		suffix = "..."
+	}
+
+	// the tabs before the error are kept so the caret lines up with it
+	var pad strings.Builder
+	for _, r := range prefix + before {
+		if r == '\t' {
+			pad.WriteByte('\t')
+		} else {
+			pad.WriteByte(' ')
+		}
+	}
+
+	width := utf8.RuneCountInString(after[:min(syntaxErr.Len, len(after))])
+	line := strconv.Itoa(pos.Line)
+
+	fmt.Fprintf(os.Stderr, " %s | %s%s%s%s\n", line, prefix, before, after, suffix)
+	fmt.Fprintf(os.Stderr, " %s | %s%s\n", strings.Repeat(" ", len(line)), pad.String(), strings.Repeat("^", max(width, 1)))
 }
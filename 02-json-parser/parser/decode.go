@@ -0,0 +1,768 @@
+package parser
+
+import (
+	"encoding"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"jp/ast"
+	"jp/lexer"
+	"jp/token"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// UnmarshalTypeError is a JSON value that can not be stored in the Go value
+// it is decoded into
+type UnmarshalTypeError struct {
+	Value string         // the JSON value, like "string" or "number 300"
+	Type  reflect.Type   // type of the Go value
+	Path  string         // JSONPath of the value, like $.items[2].price
+	Pos   token.Position // position of the value in the document
+}
+
+func (e *UnmarshalTypeError) Error() string {
+	return fmt.Sprintf("%s: cannot unmarshal %s into Go value of type %s at %s", e.Pos, e.Value, e.Type, e.Path)
+}
+
+// InvalidUnmarshalError is a value passed to Unmarshal that is not a non nil
+// pointer
+type InvalidUnmarshalError struct {
+	Type reflect.Type
+}
+
+func (e *InvalidUnmarshalError) Error() string {
+	if e.Type == nil {
+		return "Unmarshal(nil)"
+	}
+	if e.Type.Kind() != reflect.Pointer {
+		return "Unmarshal(non-pointer " + e.Type.String() + ")"
+	}
+
+	return "Unmarshal(nil " + e.Type.String() + ")"
+}
+
+// Unmarshal parses the JSON document in data and stores it in the value
+// pointed to by v, following the rules of encoding/json:
+//   - objects go in structs, matching the json tag or the name of the
+//     exported fields, case insensitively, and in maps with string, integer
+//     or encoding.TextUnmarshaler keys
+//   - arrays go in slices and arrays, extra elements of an array are dropped
+//   - strings go in strings, in []byte as base64 and in
+//     encoding.TextUnmarshaler values
+//   - json.Unmarshaler values, like json.RawMessage, are given the text of
+//     their JSON value, null included, and take precedence over
+//     encoding.TextUnmarshaler
+//   - null sets pointers, interfaces, maps and slices to nil and leaves the
+//     other values alone
+//   - in an interface{} objects become map[string]any, arrays []any,
+//     numbers float64, strings string and booleans bool
+//
+// A malformed document returns a *SyntaxError. When a value does not fit
+// its Go value the decoding goes on with the rest of the document and the
+// first *UnmarshalTypeError is returned.
+func Unmarshal(data []byte, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return &InvalidUnmarshalError{Type: reflect.TypeOf(v)}
+	}
+
+	src := string(data)
+	node, err := Parse(src)
+	if err != nil {
+		return err
+	}
+
+	d := decoder{src: src}
+	d.value(node, rv.Elem())
+
+	return d.err
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// decoder stores a tree in Go values, it keeps the path of the value being
+// decoded for the errors
+type decoder struct {
+	src  string // the document, for the json.Unmarshaler values
+	path []any  // member names and element indexes from the root
+	err  error  // first error
+}
+
+func (d *decoder) value(node ast.Node, v reflect.Value) {
+	if _, isNull := node.(*ast.Null); isNull {
+		// like encoding/json a null pointer is not allocated for its
+		// UnmarshalJSON, json.RawMessage keeps the null
+		if v.Kind() != reflect.Pointer && v.CanAddr() {
+			if u, ok := v.Addr().Interface().(json.Unmarshaler); ok {
+				d.unmarshalJSON(node, u)
+				return
+			}
+		}
+
+		switch v.Kind() {
+		case reflect.Interface, reflect.Pointer, reflect.Map, reflect.Slice:
+			v.Set(reflect.Zero(v.Type()))
+		}
+		return
+	}
+
+	v, ju, u := indirect(v)
+	if ju != nil {
+		d.unmarshalJSON(node, ju)
+		return
+	}
+	if u != nil {
+		d.text(node, v, u)
+		return
+	}
+
+	switch node := node.(type) {
+	case *ast.Object:
+		d.object(node, v)
+	case *ast.Array:
+		d.array(node, v)
+	case *ast.String:
+		d.string(node, v)
+	case *ast.Number:
+		d.number(node, node.Literal, v)
+	case *ast.Bool:
+		d.bool(node, node.Value, v)
+	}
+}
+
+// indirect follows the pointers from v, allocating the nil ones, down to a
+// value that is not a pointer, to a json.Unmarshaler or to an
+// encoding.TextUnmarshaler
+func indirect(v reflect.Value) (reflect.Value, json.Unmarshaler, encoding.TextUnmarshaler) {
+	// an interface holding a pointer is decoded into the pointed value
+	if v.Kind() == reflect.Interface && !v.IsNil() {
+		if e := v.Elem(); e.Kind() == reflect.Pointer && !e.IsNil() {
+			v = e
+		}
+	}
+
+	for {
+		if v.Kind() != reflect.Pointer && v.Type().Name() != "" && v.CanAddr() {
+			if ju, ok := v.Addr().Interface().(json.Unmarshaler); ok {
+				return v, ju, nil
+			}
+			if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+				return v, nil, u
+			}
+		}
+		if v.Kind() != reflect.Pointer {
+			return v, nil, nil
+		}
+
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		if ju, ok := v.Interface().(json.Unmarshaler); ok {
+			return v, ju, nil
+		}
+		if u, ok := v.Interface().(encoding.TextUnmarshaler); ok {
+			return v, nil, u
+		}
+		v = v.Elem()
+	}
+}
+
+func (d *decoder) object(node *ast.Object, v reflect.Value) {
+	if v.Kind() == reflect.Interface && v.NumMethod() == 0 {
+		v.Set(reflect.ValueOf(d.generic(node)))
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Map:
+		d.objectToMap(node, v)
+	case reflect.Struct:
+		d.objectToStruct(node, v)
+	default:
+		d.typeError(node, "object", v.Type())
+	}
+}
+
+func (d *decoder) objectToMap(node *ast.Object, v reflect.Value) {
+	t := v.Type()
+
+	keyType := t.Key()
+	switch keyType.Kind() {
+	case reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+	default:
+		if !reflect.PointerTo(keyType).Implements(textUnmarshalerType) {
+			d.typeError(node, "object", t)
+			return
+		}
+	}
+
+	if v.IsNil() {
+		v.Set(reflect.MakeMap(t))
+	}
+
+	for _, m := range node.Members {
+		d.path = append(d.path, m.Key.Value)
+
+		key, ok := d.mapKey(m.Key, keyType)
+		if ok {
+			elem := reflect.New(t.Elem()).Elem()
+			d.value(m.Value, elem)
+			v.SetMapIndex(key, elem)
+		}
+
+		d.path = d.path[:len(d.path)-1]
+	}
+}
+
+// mapKey converts the key of a member to the key type of a map
+func (d *decoder) mapKey(key *ast.String, t reflect.Type) (reflect.Value, bool) {
+	k := reflect.New(t)
+	if u, ok := k.Interface().(encoding.TextUnmarshaler); ok {
+		if err := u.UnmarshalText([]byte(key.Value)); err != nil {
+			d.setError(&UnmarshalTypeError{Value: "string " + strconv.Quote(key.Value), Type: t, Path: d.jsonPath(), Pos: key.Pos()})
+			return reflect.Value{}, false
+		}
+		return k.Elem(), true
+	}
+
+	k = k.Elem()
+
+	switch t.Kind() {
+	case reflect.String:
+		k.SetString(key.Value)
+		return k, true
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(key.Value, 10, 64)
+		if err != nil || k.OverflowInt(n) {
+			d.setError(&UnmarshalTypeError{Value: "number " + key.Value, Type: t, Path: d.jsonPath(), Pos: key.Pos()})
+			return reflect.Value{}, false
+		}
+		k.SetInt(n)
+
+	default:
+		n, err := strconv.ParseUint(key.Value, 10, 64)
+		if err != nil || k.OverflowUint(n) {
+			d.setError(&UnmarshalTypeError{Value: "number " + key.Value, Type: t, Path: d.jsonPath(), Pos: key.Pos()})
+			return reflect.Value{}, false
+		}
+		k.SetUint(n)
+	}
+
+	return k, true
+}
+
+func (d *decoder) objectToStruct(node *ast.Object, v reflect.Value) {
+	fields := cachedFields(v.Type())
+
+	for _, m := range node.Members {
+		f := fields.lookup(m.Key.Value)
+		if f == nil {
+			// unknown members are ignored
+			continue
+		}
+
+		fv, ok := fieldByIndex(v, f.index)
+		if !ok {
+			// a nil pointer to an unexported embedded struct can not be set
+			d.setError(&UnmarshalTypeError{Value: "object", Type: v.Type(), Path: d.jsonPath(), Pos: m.Key.Pos()})
+			continue
+		}
+
+		d.path = append(d.path, m.Key.Value)
+		if f.quoted {
+			d.quoted(m.Value, fv)
+		} else {
+			d.value(m.Value, fv)
+		}
+		d.path = d.path[:len(d.path)-1]
+	}
+}
+
+// fieldByIndex returns the field of v at index, allocating the nil embedded
+// pointers on the way
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}, false
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+
+	return v, true
+}
+
+// quoted decodes a field with the ",string" option, its boolean or number
+// is written in a string
+func (d *decoder) quoted(node ast.Node, v reflect.Value) {
+	s, isString := node.(*ast.String)
+	if !isString {
+		if _, isNull := node.(*ast.Null); isNull {
+			return
+		}
+		d.typeError(node, describeNode(node), v.Type())
+		return
+	}
+
+	t := v.Type()
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		if s.Value != "true" && s.Value != "false" {
+			d.typeError(node, "string "+strconv.Quote(s.Value), v.Type())
+			return
+		}
+		v, _, _ = indirect(v)
+		d.bool(node, s.Value == "true", v)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		v, _, _ = indirect(v)
+		d.number(node, s.Value, v)
+
+	default:
+		// the option only applies to booleans and numbers
+		d.value(node, v)
+	}
+}
+
+func (d *decoder) array(node *ast.Array, v reflect.Value) {
+	if v.Kind() == reflect.Interface && v.NumMethod() == 0 {
+		v.Set(reflect.ValueOf(d.generic(node)))
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Slice:
+		n := len(node.Elements)
+		if v.IsNil() || v.Cap() < n {
+			v.Set(reflect.MakeSlice(v.Type(), n, n))
+		} else {
+			v.SetLen(n)
+		}
+
+	case reflect.Array:
+		// the elements missing from the JSON array are zeroed
+		for i := len(node.Elements); i < v.Len(); i++ {
+			v.Index(i).Set(reflect.Zero(v.Type().Elem()))
+		}
+
+	default:
+		d.typeError(node, "array", v.Type())
+		return
+	}
+
+	for i, elem := range node.Elements {
+		if i >= v.Len() {
+			break
+		}
+
+		d.path = append(d.path, i)
+		d.value(elem, v.Index(i))
+		d.path = d.path[:len(d.path)-1]
+	}
+}
+
+func (d *decoder) string(node *ast.String, v reflect.Value) {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(node.Value)
+		return
+
+	case reflect.Interface:
+		if v.NumMethod() == 0 {
+			v.Set(reflect.ValueOf(node.Value))
+			return
+		}
+
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			b, err := base64.StdEncoding.DecodeString(node.Value)
+			if err != nil {
+				d.typeError(node, "string "+strconv.Quote(node.Value), v.Type())
+				return
+			}
+			v.SetBytes(b)
+			return
+		}
+	}
+
+	d.typeError(node, "string", v.Type())
+}
+
+// number stores the number written as lit, in the source or in a quoted
+// string
+func (d *decoder) number(node ast.Node, lit string, v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.NumMethod() == 0 {
+			f, err := strconv.ParseFloat(lit, 64)
+			if err != nil {
+				d.typeError(node, "number "+lit, v.Type())
+				return
+			}
+			v.Set(reflect.ValueOf(f))
+			return
+		}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(lit, 10, 64)
+		if err != nil || v.OverflowInt(n) {
+			d.typeError(node, "number "+lit, v.Type())
+			return
+		}
+		v.SetInt(n)
+		return
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, err := strconv.ParseUint(lit, 10, 64)
+		if err != nil || v.OverflowUint(n) {
+			d.typeError(node, "number "+lit, v.Type())
+			return
+		}
+		v.SetUint(n)
+		return
+
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(lit, v.Type().Bits())
+		if err != nil || v.OverflowFloat(f) {
+			d.typeError(node, "number "+lit, v.Type())
+			return
+		}
+		v.SetFloat(f)
+		return
+	}
+
+	d.typeError(node, "number", v.Type())
+}
+
+func (d *decoder) bool(node ast.Node, b bool, v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Bool:
+		v.SetBool(b)
+		return
+
+	case reflect.Interface:
+		if v.NumMethod() == 0 {
+			v.Set(reflect.ValueOf(b))
+			return
+		}
+	}
+
+	d.typeError(node, "bool", v.Type())
+}
+
+// text decodes a string with the UnmarshalText method of the value
+func (d *decoder) text(node ast.Node, v reflect.Value, u encoding.TextUnmarshaler) {
+	s, isString := node.(*ast.String)
+	if !isString {
+		d.typeError(node, describeNode(node), v.Type())
+		return
+	}
+
+	if err := u.UnmarshalText([]byte(s.Value)); err != nil {
+		d.typeError(node, "string "+strconv.Quote(s.Value), v.Type())
+	}
+}
+
+// unmarshalJSON gives the text of node to its json.Unmarshaler, an error of
+// UnmarshalJSON is returned as is
+func (d *decoder) unmarshalJSON(node ast.Node, u json.Unmarshaler) {
+	if err := u.UnmarshalJSON([]byte(d.source(node))); err != nil {
+		d.setError(err)
+	}
+}
+
+// source returns the text of node in the document, the tokens from its
+// position to the end of the value
+func (d *decoder) source(node ast.Node) string {
+	start := node.Pos().Offset
+	l := lexer.New(d.src[start:])
+
+	depth := 0
+	for {
+		switch tok := l.NextToken(); tok.Type {
+		case token.LBRACE, token.LBRACKET:
+			depth++
+		case token.RBRACE, token.RBRACKET:
+			depth--
+		case token.EOF, token.ILLEGAL:
+			depth = 0
+		}
+		if depth == 0 {
+			return d.src[start : start+l.Offset()]
+		}
+	}
+}
+
+// generic returns the value of node as stored in an interface{}
+func (d *decoder) generic(node ast.Node) any {
+	switch node := node.(type) {
+	case *ast.Object:
+		m := make(map[string]any, len(node.Members))
+		for _, member := range node.Members {
+			d.path = append(d.path, member.Key.Value)
+			m[member.Key.Value] = d.generic(member.Value)
+			d.path = d.path[:len(d.path)-1]
+		}
+		return m
+
+	case *ast.Array:
+		a := make([]any, len(node.Elements))
+		for i, elem := range node.Elements {
+			d.path = append(d.path, i)
+			a[i] = d.generic(elem)
+			d.path = d.path[:len(d.path)-1]
+		}
+		return a
+
+	case *ast.String:
+		return node.Value
+
+	case *ast.Number:
+		f, err := node.Float64()
+		if err != nil {
+			d.typeError(node, "number "+node.Literal, reflect.TypeOf(f))
+		}
+		return f
+
+	case *ast.Bool:
+		return node.Value
+	}
+
+	return nil
+}
+
+func (d *decoder) typeError(node ast.Node, value string, t reflect.Type) {
+	d.setError(&UnmarshalTypeError{Value: value, Type: t, Path: d.jsonPath(), Pos: node.Pos()})
+}
+
+// setError keeps the first error
+func (d *decoder) setError(err error) {
+	if d.err == nil {
+		d.err = err
+	}
+}
+
+// jsonPath returns the path of the value being decoded, like
+// $.items[2]["unit price"]
+func (d *decoder) jsonPath() string {
+	var b strings.Builder
+	b.WriteString("$")
+
+	for _, step := range d.path {
+		switch step := step.(type) {
+		case string:
+			if isIdentifier(step) {
+				b.WriteString("." + step)
+			} else {
+				b.WriteString("[" + strconv.Quote(step) + "]")
+			}
+		case int:
+			b.WriteString("[" + strconv.Itoa(step) + "]")
+		}
+	}
+
+	return b.String()
+}
+
+func isIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	for i, ch := range s {
+		isLetter := 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_'
+		if !isLetter && (i == 0 || ch < '0' || ch > '9') {
+			return false
+		}
+	}
+
+	return true
+}
+
+// describeNode returns the kind of node as shown in errors
+func describeNode(node ast.Node) string {
+	if node.Kind() == ast.BoolKind {
+		return "bool"
+	}
+
+	return node.Kind().String()
+}
+
+// field is a struct field a member can be decoded into
+type field struct {
+	name   string
+	index  []int // index of the field, through the embedded structs
+	tagged bool  // the name comes from the json tag
+	quoted bool  // the ",string" option
+}
+
+// structFields are the fields of a struct type, by JSON name
+type structFields struct {
+	list   []field
+	byName map[string]int
+}
+
+// lookup returns the field named key, an exact match first then a case
+// insensitive one
+func (fs *structFields) lookup(key string) *field {
+	if i, ok := fs.byName[key]; ok {
+		return &fs.list[i]
+	}
+
+	for i := range fs.list {
+		if strings.EqualFold(fs.list[i].name, key) {
+			return &fs.list[i]
+		}
+	}
+
+	return nil
+}
+
+var fieldCache sync.Map // map[reflect.Type]*structFields
+
+func cachedFields(t reflect.Type) *structFields {
+	if fs, ok := fieldCache.Load(t); ok {
+		return fs.(*structFields)
+	}
+
+	fs, _ := fieldCache.LoadOrStore(t, typeFields(t))
+
+	return fs.(*structFields)
+}
+
+// typeFields returns the fields of the struct type t, with the fields of
+// its untagged embedded structs. A name found at several depths belongs to
+// the shallowest field, and at the same depth to the only tagged one; the
+// other conflicting names are dropped, like encoding/json does.
+func typeFields(t reflect.Type) *structFields {
+	type candidate struct {
+		field
+		depth int
+	}
+
+	var candidates []candidate
+	visited := map[reflect.Type]bool{}
+
+	type level struct {
+		t     reflect.Type
+		index []int
+	}
+	next := []level{{t: t}}
+
+	for depth := 0; len(next) > 0; depth++ {
+		current := next
+		next = nil
+
+		for _, lv := range current {
+			if visited[lv.t] {
+				continue
+			}
+			visited[lv.t] = true
+
+			for i := 0; i < lv.t.NumField(); i++ {
+				sf := lv.t.Field(i)
+
+				ft := sf.Type
+				if ft.Kind() == reflect.Pointer {
+					ft = ft.Elem()
+				}
+
+				if sf.Anonymous {
+					if !sf.IsExported() && ft.Kind() != reflect.Struct {
+						continue
+					}
+				} else if !sf.IsExported() {
+					continue
+				}
+
+				tag := sf.Tag.Get("json")
+				if tag == "-" {
+					continue
+				}
+				name, opts, _ := strings.Cut(tag, ",")
+
+				index := append(append([]int{}, lv.index...), i)
+
+				if sf.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+					next = append(next, level{t: ft, index: index})
+					continue
+				}
+
+				f := candidate{depth: depth}
+				f.name = name
+				f.tagged = name != ""
+				if name == "" {
+					f.name = sf.Name
+				}
+				f.index = index
+
+				for _, opt := range strings.Split(opts, ",") {
+					if opt == "string" {
+						f.quoted = true
+					}
+				}
+
+				candidates = append(candidates, f)
+			}
+		}
+	}
+
+	// the dominant field of every name
+	fs := &structFields{byName: map[string]int{}}
+	byName := map[string][]candidate{}
+	var names []string
+
+	for _, c := range candidates {
+		if _, seen := byName[c.name]; !seen {
+			names = append(names, c.name)
+		}
+		byName[c.name] = append(byName[c.name], c)
+	}
+
+	for _, name := range names {
+		cs := byName[name]
+
+		top := cs[:0:0]
+		for _, c := range cs {
+			if c.depth == cs[0].depth {
+				top = append(top, c)
+			}
+		}
+
+		var dominant []candidate
+		for _, c := range top {
+			if c.tagged {
+				dominant = append(dominant, c)
+			}
+		}
+		if len(dominant) == 0 {
+			dominant = top
+		}
+
+		if len(dominant) > 1 {
+			continue
+		}
+
+		fs.byName[name] = len(fs.list)
+		fs.list = append(fs.list, dominant[0].field)
+	}
+
+	return fs
+}
@@ -0,0 +1,69 @@
+package parser
+
+import (
+	"fmt"
+	"jp/token"
+)
+
+// SyntaxError is a malformed document. It is located at the offending
+// token, or at the offending character of a malformed string.
+type SyntaxError struct {
+	Pos      token.Position // offset, line and column of the error
+	Token    token.Token    // offending token
+	Len      int            // length of the offending text in the source, in bytes
+	Expected string         // what was expected instead, like "',' or '}' after object member"
+	Msg      string         // the problem, when it is not just an unexpected token
+	Err      error          // the limit exceeded, if any, one of the ParseOptions errors
+}
+
+func (e *SyntaxError) Error() string {
+	if e.Msg != "" {
+		return fmt.Sprintf("%s: %s", e.Pos, e.Msg)
+	}
+
+	return fmt.Sprintf("%s: unexpected %s, expected %s", e.Pos, describe(e.Token), e.Expected)
+}
+
+func (e *SyntaxError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorList is the syntax errors of a document, in source order, as
+// returned by ParseRecover
+type ErrorList []*SyntaxError
+
+func (l ErrorList) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	}
+
+	return fmt.Sprintf("%s (and %d more errors)", l[0], len(l)-1)
+}
+
+func (l ErrorList) Unwrap() []error {
+	errs := make([]error, len(l))
+	for i, err := range l {
+		errs[i] = err
+	}
+
+	return errs
+}
+
+// describe returns the token as shown in error messages
+func describe(tok token.Token) string {
+	switch tok.Type {
+	case token.EOF:
+		return "end of input"
+	case token.NEWLINE:
+		return "end of line"
+	case token.STRING:
+		return fmt.Sprintf("string %q", tok.Literal)
+	case token.NUMBER:
+		return "number " + tok.Literal
+	}
+
+	return fmt.Sprintf("'%s'", tok.Literal)
+}
@@ -0,0 +1,86 @@
+package parser
+
+import "errors"
+
+// DefaultMaxDepth is the nesting limit used when ParseOptions.MaxDepth is
+// not set, the same as encoding/json
+const DefaultMaxDepth = 10000
+
+// DefaultMaxErrors is the number of errors ParseRecover reports when
+// ParseOptions.MaxErrors is not set
+const DefaultMaxErrors = 100
+
+var (
+	ErrMaxDepth        = errors.New("max depth exceeded")
+	ErrMaxStringLen    = errors.New("max string length exceeded")
+	ErrMaxDocumentSize = errors.New("max document size exceeded")
+)
+
+// Mode is the dialect of JSON the parser accepts
+type Mode int
+
+const (
+	// Strict is JSON as in RFC 8259
+	Strict Mode = iota
+
+	// JSONC is JSON with // and /* */ comments and trailing commas in
+	// arrays and objects
+	JSONC
+
+	// JSON5 is JSONC with unquoted keys, single quoted strings, hexadecimal
+	// numbers, Infinity, NaN, and the other additions of json5.org
+	JSON5
+)
+
+func (m Mode) String() string {
+	switch m {
+	case Strict:
+		return "strict"
+	case JSONC:
+		return "jsonc"
+	case JSON5:
+		return "json5"
+	}
+
+	return "unknown"
+}
+
+// ParseOptions limits what the parser accepts, so untrusted documents can
+// be parsed safely. A document over a limit returns an error wrapping
+// ErrMaxDepth, ErrMaxStringLen or ErrMaxDocumentSize.
+type ParseOptions struct {
+	// Mode is the dialect of JSON, Strict by default
+	Mode Mode
+
+	// MaxDepth is the deepest nesting of arrays and objects, DefaultMaxDepth
+	// when not positive
+	MaxDepth int
+
+	// MaxStringLen is the longest string, key or value, in bytes once
+	// decoded, no limit when not positive
+	MaxStringLen int
+
+	// MaxDocumentSize is the largest document, in bytes, no limit when not
+	// positive
+	MaxDocumentSize int
+
+	// MaxErrors is the number of syntax errors after which ParseRecover
+	// gives up, DefaultMaxErrors when not positive
+	MaxErrors int
+}
+
+func (o ParseOptions) maxDepth() int {
+	if o.MaxDepth <= 0 {
+		return DefaultMaxDepth
+	}
+
+	return o.MaxDepth
+}
+
+func (o ParseOptions) maxErrors() int {
+	if o.MaxErrors <= 0 {
+		return DefaultMaxErrors
+	}
+
+	return o.MaxErrors
+}
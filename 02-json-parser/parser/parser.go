@@ -1,36 +1,540 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"jp/ast"
 	"jp/lexer"
 	"jp/token"
-	"slices"
+	"strings"
 )
 
+type parser struct {
+	opts    ParseOptions
+	l       *lexer.Lexer
+	tok     token.Token // current token
+	end     int         // offset of the byte following tok
+	depth   int         // arrays and objects open at tok
+	discard bool        // the elements and members are not kept, to validate
+
+	recovering bool      // the syntax errors are collected in errs
+	errs       ErrorList // the syntax errors, when recovering
+}
+
+// errStop unwinds the parser recovering from errors, once it gives up
+var errStop = errors.New("too many errors")
+
+func newParser(r io.Reader, opts ParseOptions) *parser {
+	if opts.MaxDocumentSize > 0 {
+		r = &sizeLimiter{r: r, left: opts.MaxDocumentSize, limit: opts.MaxDocumentSize}
+	}
+
+	l := lexer.NewReader(r)
+	l.MaxStringLen = opts.MaxStringLen
+	l.Comments = opts.Mode == JSONC || opts.Mode == JSON5
+	l.JSON5 = opts.Mode == JSON5
+
+	return &parser{opts: opts, l: l}
+}
+
+// Parse parses a JSON document, any JSON value as in RFC 8259, and returns
+// its tree. A malformed document returns a *SyntaxError.
+func Parse(src string) (ast.Node, error) {
+	return ParseWithOptions(src, ParseOptions{})
+}
+
+// ParseWithOptions is Parse with limits on the document
+func ParseWithOptions(src string, opts ParseOptions) (ast.Node, error) {
+	return ParseReader(strings.NewReader(src), opts)
+}
+
+// ParseReader parses the JSON document read from r. The document is read
+// through a fixed size buffer, a read error is returned as is.
+func ParseReader(r io.Reader, opts ParseOptions) (ast.Node, error) {
+	p := newParser(r, opts)
+	p.next()
+
+	return p.parseDocument()
+}
+
+// ParseRecover parses the JSON document read from r like ParseReader but
+// goes on after a syntax error, the way linters do: it guesses a missing
+// ',', ':' or closing bracket, or else skips to the next ',' or closing
+// bracket and resumes there, dropping what it could not parse. It returns
+// the partial tree, nil when no value is found, and the syntax
+// errors as an ErrorList, up to opts.MaxErrors of them. An exceeded limit
+// is the last error reported, and a read error is returned as is.
+func ParseRecover(r io.Reader, opts ParseOptions) (ast.Node, error) {
+	p := newParser(r, opts)
+	p.recovering = true
+	p.next()
+
+	node, err := p.parseDocument()
+	if err != nil && err != errStop {
+		return node, err
+	}
+
+	if len(p.errs) > 0 {
+		return node, p.errs
+	}
+
+	return node, nil
+}
+
+// Validate checks the JSON document read from r without building its tree,
+// so the memory used does not depend on the size of the document
+func Validate(r io.Reader, opts ParseOptions) error {
+	p := newParser(r, opts)
+	p.discard = true
+	p.next()
+
+	_, err := p.parseDocument()
+
+	return err
+}
+
+// ParseLines parses newline delimited JSON (NDJSON or JSON Lines) read from
+// r: every line holds a document, parsed independently of the others, and
+// blank lines are skipped. fn is called with the line number and the tree,
+// or the error, of every document. ParseLines stops at the first error
+// returned by fn, which it returns, or at a read error.
+func ParseLines(r io.Reader, opts ParseOptions, fn func(line int, node ast.Node, err error) error) error {
+	p := newParser(r, opts)
+	p.l.Lines = true
+	p.next()
+
+	for {
+		for p.tok.Type == token.NEWLINE {
+			p.next()
+		}
+		if p.tok.Type == token.EOF {
+			return p.l.Err()
+		}
+
+		line := p.tok.Pos.Line
+		node, err := p.parseDocument()
+		if readErr := p.l.Err(); readErr != nil {
+			return readErr
+		}
+
+		if err != nil {
+			// resume at the next line
+			p.depth = 0
+			for p.tok.Type != token.NEWLINE && p.tok.Type != token.EOF {
+				p.next()
+			}
+		}
+
+		if err := fn(line, node, err); err != nil {
+			return err
+		}
+	}
+}
+
+// parseDocument parses the document starting at the current token, up to
+// the end of the input or of the line
+func (p *parser) parseDocument() (ast.Node, error) {
+	if !p.startsValue() {
+		if err := p.report(p.unexpected("value at the start of the document")); err != nil {
+			return nil, err
+		}
+
+		for !p.startsValue() && p.tok.Type != token.EOF {
+			p.next()
+		}
+		if p.tok.Type == token.EOF {
+			return nil, p.l.Err()
+		}
+	}
+
+	node, err := p.parseValue("")
+
+	switch {
+	case err != nil:
+	case p.l.Lines && p.tok.Type != token.NEWLINE && p.tok.Type != token.EOF:
+		err = p.unexpected("end of line after the document")
+	case !p.l.Lines && p.tok.Type != token.EOF:
+		err = p.unexpected("end of input after the document")
+	default:
+		err = p.l.Err()
+	}
+
+	if err != nil {
+		if p.recovering {
+			return node, p.report(err)
+		}
+		return nil, err
+	}
+
+	return node, nil
+}
+
+// IsValid reports whether json is a valid document, when log is set the
+// tokens are printed
 func IsValid(json string, log bool) bool {
-	tok := token.Token{
-		Type:    token.ILLEGAL,
-		Literal: "",
+	if log {
+		l := lexer.New(json)
+		for {
+			tok := l.NextToken()
+			fmt.Printf("%+v\n", tok)
+
+			if tok.Type == token.ILLEGAL || tok.Type == token.EOF {
+				break
+			}
+		}
+	}
+
+	_, err := Parse(json)
+
+	return err == nil
+}
+
+func (p *parser) next() {
+	p.tok = p.l.NextToken()
+	p.end = p.l.Offset()
+}
+
+// parseValue parses the value starting at the current token and moves past
+// it, expected describes the token in errors
+func (p *parser) parseValue(expected string) (ast.Node, error) {
+	tok := p.tok
+
+	switch tok.Type {
+	case token.LBRACE:
+		return p.parseObject()
+
+	case token.LBRACKET:
+		return p.parseArray()
+
+	case token.STRING:
+		if err := p.checkString(); err != nil {
+			return nil, err
+		}
+		p.next()
+		return &ast.String{Position: tok.Pos, Value: tok.Literal}, nil
+
+	case token.NUMBER:
+		p.next()
+		return &ast.Number{Position: tok.Pos, Literal: tok.Literal}, nil
+
+	case token.TRUE, token.FALSE:
+		p.next()
+		return &ast.Bool{Position: tok.Pos, Value: tok.Type == token.TRUE}, nil
+
+	case token.NULL:
+		p.next()
+		return &ast.Null{Position: tok.Pos}, nil
+	}
+
+	return nil, p.unexpected(expected)
+}
+
+func (p *parser) parseObject() (ast.Node, error) {
+	obj := &ast.Object{Position: p.tok.Pos}
+
+	if err := p.open(); err != nil {
+		return nil, err
+	}
+
+	if p.tok.Type == token.RBRACE {
+		p.close()
+		return obj, nil
+	}
+
+	expected := "string key or '}'"
+	for {
+		member, err := p.parseMember(expected)
+		if member != nil && !p.discard {
+			obj.Members = append(obj.Members, member)
+		}
+		if err != nil {
+			if err := p.report(err); err != nil {
+				return obj, err
+			}
+			p.skip()
+		}
+
+		done, err := p.separator(token.RBRACE, "',' or '}' after object member")
+		if done || err != nil {
+			return obj, err
+		}
+		expected = "string key after ','"
+	}
+}
+
+// parseMember parses the member starting at the current token, expected
+// describes its key in errors. The member is returned with an error when
+// it is its value that failed.
+func (p *parser) parseMember(expected string) (*ast.Member, error) {
+	if !p.isKey() {
+		return nil, p.unexpected(expected)
+	}
+	if err := p.checkString(); err != nil {
+		return nil, err
+	}
+	key := &ast.String{Position: p.tok.Pos, Value: p.tok.Literal}
+	p.next()
+
+	if p.tok.Type == token.COLON {
+		p.next()
+	} else {
+		err := p.unexpected("':' after object key")
+		if !p.recovering || !p.startsValue() {
+			return nil, err
+		}
+
+		// a missing ':', the value follows the key
+		if err := p.report(err); err != nil {
+			return nil, err
+		}
+	}
+
+	value, err := p.parseValue("value after ':'")
+	if value == nil {
+		return nil, err
+	}
+
+	return &ast.Member{Key: key, Value: value}, err
+}
+
+func (p *parser) parseArray() (ast.Node, error) {
+	arr := &ast.Array{Position: p.tok.Pos}
+
+	if err := p.open(); err != nil {
+		return nil, err
 	}
 
-	l := lexer.New(json)
+	if p.tok.Type == token.RBRACKET {
+		p.close()
+		return arr, nil
+	}
 
+	expected := "value or ']'"
 	for {
-		nextToken := l.NextToken()
-		if log {
-			fmt.Printf("%+v\n", nextToken)
+		value, err := p.parseValue(expected)
+		if value != nil && !p.discard {
+			arr.Elements = append(arr.Elements, value)
+		}
+		if err != nil {
+			if err := p.report(err); err != nil {
+				return arr, err
+			}
+			p.skip()
 		}
 
-		if nextToken.Type == token.ILLEGAL {
-			return false
+		done, err := p.separator(token.RBRACKET, "',' or ']' after array element")
+		if done || err != nil {
+			return arr, err
 		}
-		if nextToken.Type == token.EOF {
-			break
+		expected = "value after ','"
+	}
+}
+
+// separator moves past the ',' or the closing bracket following an element
+// or a member, expected describes them in errors. done reports the end of
+// the array or object: its closing bracket was consumed or, when
+// recovering, is missing.
+func (p *parser) separator(closing token.TokenType, expected string) (done bool, err error) {
+	for {
+		switch p.tok.Type {
+		case token.COMMA:
+			p.next()
+
+			if p.tok.Type == closing && p.opts.Mode != Strict {
+				// trailing comma
+				p.close()
+				return true, nil
+			}
+			return false, nil
+
+		case closing:
+			p.close()
+			return true, nil
+		}
+
+		if err := p.report(p.unexpected(expected)); err != nil {
+			return true, err
+		}
+
+		switch {
+		case p.tok.Type == token.RBRACE || p.tok.Type == token.RBRACKET || p.tok.Type == token.EOF:
+			// the closing bracket is missing, the one found is the parent's
+			p.depth--
+			return true, nil
+
+		case closing == token.RBRACKET && p.startsValue(), closing == token.RBRACE && p.isKey():
+			// a missing ','
+			return false, nil
 		}
 
-		tok.Type = nextToken.Type
-		tok.Literal = nextToken.Literal
+		p.skip()
 	}
+}
+
+// open moves past the opening bracket of an array or an object
+func (p *parser) open() error {
+	if limit := p.opts.maxDepth(); p.depth == limit {
+		return &SyntaxError{
+			Pos:   p.tok.Pos,
+			Token: p.tok,
+			Len:   1,
+			Msg:   fmt.Sprintf("%v, more than %d nested arrays and objects", ErrMaxDepth, limit),
+			Err:   ErrMaxDepth,
+		}
+	}
+
+	p.depth++
+	p.next()
+
+	return nil
+}
+
+// startsValue reports whether a value starts at the current token
+func (p *parser) startsValue() bool {
+	switch p.tok.Type {
+	case token.LBRACE, token.LBRACKET, token.STRING, token.NUMBER, token.TRUE, token.FALSE, token.NULL:
+		return true
+	}
+
+	return false
+}
+
+// isKey reports whether the current token can be the key of a member, in
+// JSON5 an identifier, a keyword, Infinity or NaN is one
+func (p *parser) isKey() bool {
+	switch p.tok.Type {
+	case token.STRING:
+		return true
+	case token.IDENT, token.TRUE, token.FALSE, token.NULL:
+		return p.opts.Mode == JSON5
+	case token.NUMBER:
+		return p.opts.Mode == JSON5 && (p.tok.Literal == "Infinity" || p.tok.Literal == "NaN")
+	}
+
+	return false
+}
+
+// checkString returns an error when the current string token is over the
+// length limit
+func (p *parser) checkString() error {
+	if limit := p.opts.MaxStringLen; limit > 0 && len(p.tok.Literal) > limit {
+		return &SyntaxError{
+			Pos:   p.tok.Pos,
+			Token: p.tok,
+			Len:   p.end - p.tok.Pos.Offset,
+			Msg:   fmt.Sprintf("%v, the limit is %d bytes", ErrMaxStringLen, limit),
+			Err:   ErrMaxStringLen,
+		}
+	}
+
+	return nil
+}
+
+// close moves past the closing bracket of an array or an object
+func (p *parser) close() {
+	p.depth--
+	p.next()
+}
+
+// report records err when recovering, and returns nil if the parser can go
+// on. Otherwise, or when err is not a syntax error, it returns the error
+// to unwind the parser with.
+func (p *parser) report(err error) error {
+	syntaxErr, ok := err.(*SyntaxError)
+	if !p.recovering || !ok {
+		return err
+	}
+
+	// the containers left by an error report it again, at the same place
+	if n := len(p.errs); n == 0 || p.errs[n-1].Pos.Offset != syntaxErr.Pos.Offset {
+		p.errs = append(p.errs, syntaxErr)
+	}
+
+	if syntaxErr.Err != nil || len(p.errs) >= p.opts.maxErrors() {
+		return errStop
+	}
+
+	return nil
+}
+
+// skip moves, after an error, to the next ',' or closing bracket out of the
+// arrays and objects it skips, or to the end of the input
+func (p *parser) skip() {
+	depth := 0
+
+	for {
+		switch p.tok.Type {
+		case token.EOF:
+			return
+
+		case token.LBRACE, token.LBRACKET:
+			depth++
+
+		case token.RBRACE, token.RBRACKET:
+			if depth == 0 {
+				return
+			}
+			depth--
+
+		case token.COMMA:
+			if depth == 0 {
+				return
+			}
+		}
+
+		p.next()
+	}
+}
+
+// unexpected returns the error for the current token, found where
+// expected was. A read error is returned as is, the token is then just
+// where the reading stopped.
+func (p *parser) unexpected(expected string) error {
+	if err := p.l.Err(); err != nil {
+		return err
+	}
+
+	err := &SyntaxError{
+		Pos:      p.tok.Pos,
+		Token:    p.tok,
+		Len:      p.end - p.tok.Pos.Offset,
+		Expected: expected,
+	}
+
+	if p.tok.Type == token.EOF {
+		err.Len = 0
+	}
+
+	if problem := p.l.Problem(); p.tok.Type == token.ILLEGAL && problem != nil {
+		err.Pos = problem.Pos
+		err.Len = problem.Len
+		err.Msg = problem.Msg
+	}
+
+	return err
+}
+
+// sizeLimiter reads from r and fails with ErrMaxDocumentSize once more
+// than limit bytes are read
+type sizeLimiter struct {
+	r     io.Reader
+	left  int
+	limit int
+}
+
+func (s *sizeLimiter) Read(p []byte) (int, error) {
+	if s.left < 0 {
+		return 0, fmt.Errorf("%w, the limit is %d bytes", ErrMaxDocumentSize, s.limit)
+	}
+
+	// one byte more than allowed tells the document is too large
+	if len(p)-1 > s.left {
+		p = p[:s.left+1]
+	}
+
+	n, err := s.r.Read(p)
+	s.left -= n
 
-	return slices.Contains([]token.TokenType{token.RBRACE, token.RBRACKET}, tok.Type)
+	return n, err
 }
@@ -1,8 +1,22 @@
 package parser
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"jp/ast"
+	"jp/format"
+	"jp/token"
+	"math"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
+	"testing/iotest"
 )
 
 func Test_isValid(t *testing.T) {
@@ -67,9 +81,10 @@ func Test_isValid(t *testing.T) {
 			want: true,
 		},
 		{
+			// a string, refused by JSON_checker but valid since RFC 7159
 			name: "official test - fail 1",
 			file: "../test/fail1.json",
-			want: false,
+			want: true,
 		},
 		{
 			name: "official test - fail 2",
@@ -152,9 +167,10 @@ func Test_isValid(t *testing.T) {
 			want: false,
 		},
 		{
+			// only too deep for JSON_checker, see Test_ParseWithOptions
 			name: "official test - fail 18",
 			file: "../test/fail18.json",
-			want: false,
+			want: true,
 		},
 		{
 			name: "official test - fail 19",
@@ -260,3 +276,1577 @@ func Test_isValid(t *testing.T) {
 		})
 	}
 }
+
+func Test_Parse(t *testing.T) {
+	pos := func(offset, line, column int) token.Position {
+		return token.Position{Offset: offset, Line: line, Column: column}
+	}
+
+	tests := []struct {
+		name    string
+		src     string
+		want    ast.Node
+		wantErr string
+	}{
+		{
+			name: "empty object",
+			src:  "{}",
+			want: &ast.Object{Position: pos(0, 1, 1)},
+		},
+		{
+			name: "every kind of value",
+			src:  "{\n  \"a\": [1, \"x\", true],\n  \"b\": {\"c\": null, \"d\": false}\n}",
+			want: &ast.Object{
+				Position: pos(0, 1, 1),
+				Members: []*ast.Member{
+					{
+						Key: &ast.String{Position: pos(4, 2, 3), Value: "a"},
+						Value: &ast.Array{
+							Position: pos(9, 2, 8),
+							Elements: []ast.Node{
+								&ast.Number{Position: pos(10, 2, 9), Literal: "1"},
+								&ast.String{Position: pos(13, 2, 12), Value: "x"},
+								&ast.Bool{Position: pos(18, 2, 17), Value: true},
+							},
+						},
+					},
+					{
+						Key: &ast.String{Position: pos(27, 3, 3), Value: "b"},
+						Value: &ast.Object{
+							Position: pos(32, 3, 8),
+							Members: []*ast.Member{
+								{
+									Key:   &ast.String{Position: pos(33, 3, 9), Value: "c"},
+									Value: &ast.Null{Position: pos(38, 3, 14)},
+								},
+								{
+									Key:   &ast.String{Position: pos(44, 3, 20), Value: "d"},
+									Value: &ast.Bool{Position: pos(49, 3, 25), Value: false},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "numbers are kept verbatim",
+			src:  "[-0.50, 1E+2]",
+			want: &ast.Array{
+				Position: pos(0, 1, 1),
+				Elements: []ast.Node{
+					&ast.Number{Position: pos(1, 1, 2), Literal: "-0.50"},
+					&ast.Number{Position: pos(8, 1, 9), Literal: "1E+2"},
+				},
+			},
+		},
+		{
+			name:    "missing comma",
+			src:     "{\"a\": 1\n \"b\": 2}",
+			wantErr: "2:2: unexpected string \"b\", expected ',' or '}' after object member",
+		},
+		{
+			name:    "trailing data",
+			src:     "[] []",
+			wantErr: "1:4: unexpected '[', expected end of input after the document",
+		},
+		{
+			name:    "unterminated array",
+			src:     "[1,",
+			wantErr: "1:4: unexpected end of input, expected value after ','",
+		},
+		{
+			name:    "minus inside a number",
+			src:     "[1-2]",
+			wantErr: "1:2: unexpected '1-2', expected value or ']'",
+		},
+		{
+			name: "scalar document",
+			src:  " -12.5e+3 ",
+			want: &ast.Number{Position: pos(1, 1, 2), Literal: "-12.5e+3"},
+		},
+		{
+			name: "string document",
+			src:  `"a"`,
+			want: &ast.String{Position: pos(0, 1, 1), Value: "a"},
+		},
+		{
+			name:    "empty document",
+			src:     " \n",
+			wantErr: "2:1: unexpected end of input, expected value at the start of the document",
+		},
+		{
+			name:    "two scalars",
+			src:     "1 2",
+			wantErr: "1:3: unexpected number 2, expected end of input after the document",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.src)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("Parse() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_Lookup(t *testing.T) {
+	root, err := Parse(`{"a": {"b": [10, {"c": "x"}]}, "k": 1, "k": 2}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		path   []any
+		want   string
+		wantOk bool
+	}{
+		{
+			name:   "nested",
+			path:   []any{"a", "b", 1, "c"},
+			want:   "x",
+			wantOk: true,
+		},
+		{
+			name:   "repeated key, the last one wins",
+			path:   []any{"k"},
+			want:   "2",
+			wantOk: true,
+		},
+		{
+			name: "missing key",
+			path: []any{"a", "z"},
+		},
+		{
+			name: "index out of range",
+			path: []any{"a", "b", 2},
+		},
+		{
+			name: "index of an object",
+			path: []any{"a", 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node, ok := ast.Lookup(root, tt.path...)
+			if ok != tt.wantOk {
+				t.Fatalf("Lookup() ok = %v, want %v", ok, tt.wantOk)
+			}
+			if !ok {
+				return
+			}
+
+			var got string
+			switch n := node.(type) {
+			case *ast.String:
+				got = n.Value
+			case *ast.Number:
+				got = n.Literal
+			}
+			if got != tt.want {
+				t.Errorf("Lookup() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if got, want := root.(*ast.Object).Keys(), []string{"a", "k", "k"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Keys() = %v, want %v", got, want)
+	}
+}
+
+func Test_Equal(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{a: `[1, "a", true, null]`, b: `[1, "a", true, null]`, want: true},
+		{a: `[1]`, b: `[1.0e0]`, want: true},
+		{a: `[1]`, b: `["1"]`, want: false},
+		{a: `[1, 2]`, b: `[2, 1]`, want: false},
+		{a: `[[]]`, b: `[{}]`, want: false},
+		{a: `{"a": 1, "b": [2]}`, b: `{"b": [2], "a": 1}`, want: true},
+		{a: `{"a": 1, "a": 2}`, b: `{"a": 2}`, want: true},
+		{a: `{"a": 1}`, b: `{"a": 1, "b": 1}`, want: false},
+		{a: `{"a": null}`, b: `{"b": null}`, want: false},
+		{a: `[0.10, -0, 1E2]`, b: `[1e-1, 0.0, 100]`, want: true},
+		{a: `[12345678901234567890]`, b: `[12345678901234567891]`, want: false},
+		{a: `[1e400]`, b: `[10e399]`, want: true},
+		{a: `[1e400]`, b: `[1e401]`, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.a+" "+tt.b, func(t *testing.T) {
+			a, err := Parse(tt.a)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := Parse(tt.b)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := ast.Equal(a, b); got != tt.want {
+				t.Errorf("Equal() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_Compare(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{a: `1`, b: `1.0e0`, want: 0},
+		{a: `-0`, b: `0.0`, want: 0},
+		{a: `1`, b: `2`, want: -1},
+		{a: `-1`, b: `-2`, want: 1},
+		{a: `-1`, b: `0`, want: -1},
+		{a: `0.5`, b: `-1e9`, want: 1},
+		{a: `9007199254740992`, b: `9007199254740993`, want: -1},
+		{a: `12.3`, b: `12.25`, want: 1},
+		{a: `12`, b: `12.0001`, want: -1},
+		{a: `99`, b: `1e2`, want: -1},
+		{a: `1e400`, b: `1e401`, want: -1},
+		{a: `-1e-400`, b: `-1e-401`, want: -1},
+		{a: `0x10`, b: `15.5`, want: 1},
+		{a: `Infinity`, b: `1e400`, want: 1},
+		{a: `-Infinity`, b: `-1e400`, want: -1},
+		{a: `NaN`, b: `1`, want: 0},
+		{a: `1`, b: `NaN`, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.a+" "+tt.b, func(t *testing.T) {
+			a, err := ParseWithOptions(tt.a, ParseOptions{Mode: JSON5})
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := ParseWithOptions(tt.b, ParseOptions{Mode: JSON5})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := ast.Compare(a.(*ast.Number), b.(*ast.Number)); got != tt.want {
+				t.Errorf("Compare() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("filters", func(t *testing.T) {
+		root, err := Parse(`[9007199254740992, 9007199254740993]`)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for expr, want := range map[string]string{
+			"$[?(@ == 9007199254740992)]": `[9007199254740992]`,
+			"$[?(@ > 9007199254740992)]":  `[9007199254740993]`,
+			"$[?(@ < 9007199254740993)]":  `[9007199254740992]`,
+		} {
+			nodes, err := Query(root, expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := format.String(&ast.Array{Elements: nodes}, format.Options{}); got != want {
+				t.Errorf("Query(%s) = %v, want %v", expr, got, want)
+			}
+		}
+	})
+}
+
+func Test_NumberGrammar(t *testing.T) {
+	tests := []struct {
+		src   string
+		valid bool
+	}{
+		{"0", true},
+		{"-0", true},
+		{"12", true},
+		{"-12.50", true},
+		{"1e5", true},
+		{"1E+5", true},
+		{"1.5e-05", true},
+		{"123456789012345678901234567890", true},
+		{"-", false},
+		{"1-2", false},
+		{"--1", false},
+		{"+1", false},
+		{"01", false},
+		{"-01", false},
+		{"1.", false},
+		{".5", false},
+		{"1.e5", false},
+		{"1e", false},
+		{"1e+", false},
+		{"1e5.5", false},
+		{"0x10", false},
+		{"1f", false},
+		{"Infinity", false},
+		{"NaN", false},
+		{"1.2.3", false},
+		{"1e2e3", false},
+		{"-e1", false},
+		// e and E start words, not numbers
+		{"e1", false},
+		{"E", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			node, err := Parse(tt.src)
+			if (err == nil) != tt.valid {
+				t.Fatalf("Parse() error = %v, want error %v", err, !tt.valid)
+			}
+			if err == nil && node.(*ast.Number).Literal != tt.src {
+				t.Errorf("Parse() = %q, want %q", node.(*ast.Number).Literal, tt.src)
+			}
+		})
+	}
+}
+
+func Test_Number(t *testing.T) {
+	type result struct {
+		value any
+		err   error
+	}
+
+	tests := []struct {
+		lit      string
+		int64    result
+		float64  result
+		bigInt   result // the value as a string
+		bigFloat result // the value as a string
+	}{
+		{
+			lit:      "42",
+			int64:    result{int64(42), nil},
+			float64:  result{42.0, nil},
+			bigInt:   result{"42", nil},
+			bigFloat: result{"42", nil},
+		},
+		{
+			lit:      "-1.5e3",
+			int64:    result{int64(-1500), nil},
+			float64:  result{-1500.0, nil},
+			bigInt:   result{"-1500", nil},
+			bigFloat: result{"-1500", nil},
+		},
+		{
+			lit:      "0.25",
+			int64:    result{int64(0), ast.ErrNotInteger},
+			float64:  result{0.25, nil},
+			bigInt:   result{"", ast.ErrNotInteger},
+			bigFloat: result{"0.25", nil},
+		},
+		{
+			lit:      "-0.0e7",
+			int64:    result{int64(0), nil},
+			float64:  result{math.Copysign(0, -1), nil},
+			bigInt:   result{"0", nil},
+			bigFloat: result{"-0", nil},
+		},
+		{
+			lit:      "9223372036854775807",
+			int64:    result{int64(math.MaxInt64), nil},
+			float64:  result{9223372036854775807.0, nil},
+			bigInt:   result{"9223372036854775807", nil},
+			bigFloat: result{"9.223372036854775807e+18", nil},
+		},
+		{
+			lit:      "9223372036854775808",
+			int64:    result{int64(0), ast.ErrRange},
+			float64:  result{9223372036854775808.0, nil},
+			bigInt:   result{"9223372036854775808", nil},
+			bigFloat: result{"9.223372036854775808e+18", nil},
+		},
+		{
+			lit:      "123456789012345678901234567890.5",
+			int64:    result{int64(0), ast.ErrNotInteger},
+			float64:  result{123456789012345678901234567890.5, nil},
+			bigInt:   result{"", ast.ErrNotInteger},
+			bigFloat: result{"1.234567890123456789012345678905e+29", nil},
+		},
+		{
+			lit:      "1e400",
+			int64:    result{int64(0), ast.ErrRange},
+			float64:  result{math.Inf(1), ast.ErrRange},
+			bigInt:   result{"1" + strings.Repeat("0", 400), nil},
+			bigFloat: result{"1e+400", nil},
+		},
+		{
+			lit:      "1e-400",
+			int64:    result{int64(0), ast.ErrNotInteger},
+			float64:  result{0.0, nil},
+			bigInt:   result{"", ast.ErrNotInteger},
+			bigFloat: result{"1e-400", nil},
+		},
+		{
+			lit:      "1e1000000000",
+			int64:    result{int64(0), ast.ErrRange},
+			float64:  result{math.Inf(1), ast.ErrRange},
+			bigInt:   result{"", ast.ErrRange},
+			bigFloat: result{"", ast.ErrRange},
+		},
+		{
+			lit:      "-1e99999999999999999999",
+			int64:    result{int64(0), ast.ErrRange},
+			float64:  result{math.Inf(-1), ast.ErrRange},
+			bigInt:   result{"", ast.ErrRange},
+			bigFloat: result{"", ast.ErrRange},
+		},
+		{
+			lit:      "0e99999999999999999999",
+			int64:    result{int64(0), nil},
+			float64:  result{0.0, nil},
+			bigInt:   result{"0", nil},
+			bigFloat: result{"0", nil},
+		},
+		{
+			lit:      "-0x1F",
+			int64:    result{int64(-31), nil},
+			float64:  result{-31.0, nil},
+			bigInt:   result{"-31", nil},
+			bigFloat: result{"-31", nil},
+		},
+		{
+			lit:      "+.5",
+			int64:    result{int64(0), ast.ErrNotInteger},
+			float64:  result{0.5, nil},
+			bigInt:   result{"", ast.ErrNotInteger},
+			bigFloat: result{"0.5", nil},
+		},
+		{
+			lit:      "-Infinity",
+			int64:    result{int64(0), ast.ErrNotInteger},
+			float64:  result{math.Inf(-1), nil},
+			bigInt:   result{"", ast.ErrNotInteger},
+			bigFloat: result{"-Inf", nil},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.lit, func(t *testing.T) {
+			n := &ast.Number{Literal: tt.lit}
+
+			i, err := n.Int64()
+			if got := (result{i, err}); got != tt.int64 {
+				t.Errorf("Int64() = %v, want %v", got, tt.int64)
+			}
+
+			f, err := n.Float64()
+			if got := (result{f, err}); got != tt.float64 || math.Signbit(f) != math.Signbit(tt.float64.value.(float64)) {
+				t.Errorf("Float64() = %v, want %v", got, tt.float64)
+			}
+
+			bi, err := n.BigInt()
+			got := result{"", err}
+			if err == nil {
+				got.value = bi.String()
+			}
+			if got != tt.bigInt {
+				t.Errorf("BigInt() = %v, want %v", got, tt.bigInt)
+			}
+
+			bf, err := n.BigFloat()
+			got = result{"", err}
+			if err == nil {
+				got.value = bf.Text('g', -1)
+			}
+			if got != tt.bigFloat {
+				t.Errorf("BigFloat() = %v, want %v", got, tt.bigFloat)
+			}
+		})
+	}
+
+	// NaN is no number but its own value
+	f, err := (&ast.Number{Literal: "-NaN"}).Float64()
+	if !math.IsNaN(f) || err != nil {
+		t.Errorf("Float64() = %v, %v, want NaN", f, err)
+	}
+	if _, err := (&ast.Number{Literal: "NaN"}).BigFloat(); err != ast.ErrRange {
+		t.Errorf("BigFloat() error = %v, want %v", err, ast.ErrRange)
+	}
+}
+
+func Test_SyntaxError(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want SyntaxError
+	}{
+		{
+			name: "unquoted key",
+			src:  "{\n  \"key\": \"value\",\n  key2: \"value\"\n}",
+			want: SyntaxError{
+				Pos:      token.Position{Offset: 22, Line: 3, Column: 3},
+				Token:    token.Token{Type: token.ILLEGAL, Literal: "key2", Pos: token.Position{Offset: 22, Line: 3, Column: 3}},
+				Len:      4,
+				Expected: "string key after ','",
+			},
+		},
+		{
+			name: "tab in a string",
+			src:  "[\"a\tb\"]",
+			want: SyntaxError{
+				Pos:      token.Position{Offset: 3, Line: 1, Column: 4},
+				Token:    token.Token{Type: token.ILLEGAL, Literal: "\"a", Pos: token.Position{Offset: 1, Line: 1, Column: 2}},
+				Len:      1,
+				Expected: "value or ']'",
+				Msg:      "invalid character '\\t' in string",
+			},
+		},
+		{
+			name: "unterminated string",
+			src:  "[\"abc",
+			want: SyntaxError{
+				Pos:      token.Position{Offset: 1, Line: 1, Column: 2},
+				Token:    token.Token{Type: token.ILLEGAL, Literal: "\"abc", Pos: token.Position{Offset: 1, Line: 1, Column: 2}},
+				Len:      4,
+				Expected: "value or ']'",
+				Msg:      "unterminated string",
+			},
+		},
+		{
+			name: "non ASCII character",
+			src:  "[é]",
+			want: SyntaxError{
+				Pos:      token.Position{Offset: 1, Line: 1, Column: 2},
+				Token:    token.Token{Type: token.ILLEGAL, Literal: "é", Pos: token.Position{Offset: 1, Line: 1, Column: 2}},
+				Len:      2,
+				Expected: "value or ']'",
+			},
+		},
+		{
+			name: "smart quotes",
+			src:  "{\"a\": “b”}",
+			want: SyntaxError{
+				Pos:      token.Position{Offset: 6, Line: 1, Column: 7},
+				Token:    token.Token{Type: token.ILLEGAL, Literal: "“", Pos: token.Position{Offset: 6, Line: 1, Column: 7}},
+				Len:      3,
+				Expected: "value after ':'",
+			},
+		},
+		{
+			name: "invalid UTF-8",
+			src:  "[\xc3]",
+			want: SyntaxError{
+				Pos:      token.Position{Offset: 1, Line: 1, Column: 2},
+				Token:    token.Token{Type: token.ILLEGAL, Literal: "\xc3", Pos: token.Position{Offset: 1, Line: 1, Column: 2}},
+				Len:      1,
+				Expected: "value or ']'",
+			},
+		},
+		{
+			name: "missing value",
+			src:  "{\"a\": }",
+			want: SyntaxError{
+				Pos:      token.Position{Offset: 6, Line: 1, Column: 7},
+				Token:    token.Token{Type: token.RBRACE, Literal: "}", Pos: token.Position{Offset: 6, Line: 1, Column: 7}},
+				Len:      1,
+				Expected: "value after ':'",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.src)
+
+			var got *SyntaxError
+			if !errors.As(err, &got) {
+				t.Fatalf("Parse() error = %v, want a *SyntaxError", err)
+			}
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("Parse() error = %#v, want %#v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_ParseString(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		want    string
+		wantErr string
+	}{
+		{
+			name: "empty",
+			src:  `""`,
+			want: "",
+		},
+		{
+			name: "escaped quote",
+			src:  `"a\"b"`,
+			want: `a"b`,
+		},
+		{
+			name: "every short escape",
+			src:  `"\"\\\/\b\f\n\r\t"`,
+			want: "\"\\/\b\f\n\r\t",
+		},
+		{
+			name: "raw non ascii",
+			src:  `"é中"`,
+			want: "é中",
+		},
+		{
+			name: "unicode escape",
+			src:  `"\u00e9\u4e2d"`,
+			want: "é中",
+		},
+		{
+			name: "surrogate pair",
+			src:  `"\ud83d\ude00"`,
+			want: "😀",
+		},
+		{
+			name: "unpaired high surrogate",
+			src:  `"\ud83dx"`,
+			want: "\uFFFDx",
+		},
+		{
+			name: "high surrogate followed by a non surrogate escape",
+			src:  `"\ud83d\u0041"`,
+			want: "\uFFFDA",
+		},
+		{
+			name: "lone low surrogate",
+			src:  `"\ude00"`,
+			want: "\uFFFD",
+		},
+		{
+			name: "invalid utf-8",
+			src:  "\"a\xffb\"",
+			want: "a\uFFFDb",
+		},
+		{
+			name:    "unknown escape",
+			src:     `"\x41"`,
+			wantErr: `1:3: invalid escape sequence '\x' in string`,
+		},
+		{
+			name:    "short unicode escape",
+			src:     `"\u12"`,
+			wantErr: `1:3: invalid escape sequence '\u12' in string`,
+		},
+		{
+			name:    "escaped newline",
+			src:     "\"line\\\nbreak\"",
+			wantErr: `1:7: invalid character '\n' after '\' in string`,
+		},
+		{
+			name:    "escaped non ASCII character",
+			src:     `"\é"`,
+			wantErr: `1:3: invalid escape sequence '\é' in string`,
+		},
+		{
+			name:    "raw control character",
+			src:     "\"a\x01\"",
+			wantErr: `1:4: invalid character '\x01' in string`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// strings are parsed as an array element and as a key
+			for _, src := range []string{"[" + tt.src + "]", "{" + tt.src + ": 0}"} {
+				node, err := Parse(src)
+				if tt.wantErr != "" {
+					if err == nil || err.Error() != tt.wantErr {
+						t.Fatalf("Parse(%s) error = %v, want %q", src, err, tt.wantErr)
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatalf("Parse(%s) error = %v", src, err)
+				}
+
+				var got string
+				switch n := node.(type) {
+				case *ast.Array:
+					got = n.Elements[0].(*ast.String).Value
+				case *ast.Object:
+					got = n.Members[0].Key.Value
+				}
+				if got != tt.want {
+					t.Errorf("Parse(%s) = %q, want %q", src, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func Test_ParseWithOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		opts    ParseOptions
+		wantErr error
+	}{
+		{
+			name: "deep document with the default depth",
+			src:  strings.Repeat("[", 1000) + strings.Repeat("]", 1000),
+		},
+		{
+			name:    "too deep arrays",
+			src:     "[[[[[[[[[[[[[[[[[[[[\"Too deep\"]]]]]]]]]]]]]]]]]]]]",
+			opts:    ParseOptions{MaxDepth: 19},
+			wantErr: ErrMaxDepth,
+		},
+		{
+			name:    "too deep objects",
+			src:     `{"a": {"b": {}}}`,
+			opts:    ParseOptions{MaxDepth: 2},
+			wantErr: ErrMaxDepth,
+		},
+		{
+			name:    "too deep with the default depth",
+			src:     strings.Repeat("[", DefaultMaxDepth+1) + strings.Repeat("]", DefaultMaxDepth+1),
+			wantErr: ErrMaxDepth,
+		},
+		{
+			name: "depth at the limit",
+			src:  `{"a": [{}]}`,
+			opts: ParseOptions{MaxDepth: 3},
+		},
+		{
+			name:    "long value",
+			src:     `["abcd"]`,
+			opts:    ParseOptions{MaxStringLen: 3},
+			wantErr: ErrMaxStringLen,
+		},
+		{
+			name:    "long key",
+			src:     `{"abcd": 1}`,
+			opts:    ParseOptions{MaxStringLen: 3},
+			wantErr: ErrMaxStringLen,
+		},
+		{
+			name: "decoded length",
+			src:  `["\u0041\u0042\u0043"]`,
+			opts: ParseOptions{MaxStringLen: 3},
+		},
+		{
+			name:    "large document",
+			src:     `[1, 2, 3]`,
+			opts:    ParseOptions{MaxDocumentSize: 8},
+			wantErr: ErrMaxDocumentSize,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseWithOptions(tt.src, tt.opts)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("ParseWithOptions() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// Test_Modes runs the fixtures of every mode: the pass files must parse,
+// to the tree of the JSON file of the same name when there is one, and
+// the fail files must not. A mode accepts the documents of the stricter
+// ones.
+func Test_Modes(t *testing.T) {
+	fixtures := []struct {
+		mode  Mode
+		files string
+	}{
+		{mode: Strict, files: "../test/*.json"},
+		{mode: JSONC, files: "../test/jsonc/*.jsonc"},
+		{mode: JSON5, files: "../test/json5/*.json5"},
+	}
+
+	for _, fixture := range fixtures {
+		files, err := filepath.Glob(fixture.files)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(files) == 0 {
+			t.Fatalf("no fixture for %v", fixture.mode)
+		}
+
+		for _, file := range files {
+			pass := strings.HasPrefix(filepath.Base(file), "pass")
+
+			t.Run(fixture.mode.String()+"/"+filepath.Base(file), func(t *testing.T) {
+				switch filepath.Base(file) {
+				case "fail1.json":
+					t.Skip("a string, valid since RFC 7159, see Test_isValid")
+				case "fail18.json":
+					t.Skip("only too deep for JSON_checker, see Test_isValid")
+				}
+
+				buf, err := os.ReadFile(file)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				for mode := Strict; mode <= JSON5; mode++ {
+					node, err := ParseWithOptions(string(buf), ParseOptions{Mode: mode})
+
+					// the pass files use what their mode adds, the fail files
+					// may be fixed by a laxer mode
+					if !pass && mode > fixture.mode {
+						continue
+					}
+					if valid := pass && mode >= fixture.mode; (err == nil) != valid {
+						t.Errorf("ParseWithOptions() in %v mode error = %v, want error %v", mode, err, !valid)
+					}
+
+					if err != nil || mode != fixture.mode {
+						continue
+					}
+
+					twin, err := os.ReadFile(strings.TrimSuffix(file, filepath.Ext(file)) + ".json")
+					if err != nil || fixture.mode == Strict {
+						continue
+					}
+					want, err := Parse(string(twin))
+					if err != nil {
+						t.Fatal(err)
+					}
+					if !ast.Equal(node, want) {
+						t.Errorf("ParseWithOptions() = %v, want %v", format.String(node, format.Options{}), format.String(want, format.Options{}))
+					}
+				}
+			})
+		}
+	}
+}
+
+func Test_ParseModeErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		mode    Mode
+		wantErr string
+	}{
+		{name: "trailing comma in strict mode", src: `[1,]`, mode: Strict, wantErr: "1:4: unexpected ']', expected value after ','"},
+		{name: "comment in strict mode", src: `[1] // x`, mode: Strict, wantErr: "1:5: unexpected '/', expected end of input after the document"},
+		{name: "unterminated comment", src: "[1]\n/* x", mode: JSONC, wantErr: "2:1: unterminated comment"},
+		{name: "digit escape", src: `['\1']`, mode: JSON5, wantErr: `1:3: invalid escape sequence '\1' in string`},
+		{name: "short hex escape", src: `['\x4']`, mode: JSON5, wantErr: `1:3: invalid escape sequence '\x4' in string`},
+		{name: "line break in a string", src: "['a\nb']", mode: JSON5, wantErr: `1:4: invalid character '\n' in string`},
+		{name: "key with a space", src: `{a b: 1}`, mode: JSON5, wantErr: "1:4: unexpected 'b', expected ':' after object key"},
+		{name: "identifier value", src: `[a]`, mode: JSON5, wantErr: "1:2: unexpected 'a', expected value or ']'"},
+		{name: "key starting with e", src: `{enabled: true, Echo: 1 2}`, mode: JSON5, wantErr: "1:25: unexpected number 2, expected ',' or '}' after object member"},
+		{name: "unquoted key in strict mode", src: `{enabled: true}`, mode: Strict, wantErr: "1:2: unexpected 'enabled', expected string key or '}'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseWithOptions(tt.src, ParseOptions{Mode: tt.mode})
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("ParseWithOptions() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_ParseReader(t *testing.T) {
+	// escapes and numbers across the 64KiB buffer boundaries
+	var sb strings.Builder
+	sb.WriteString(`{"list": [`)
+	for i := 0; i < 20000; i++ {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(`"a\"é😀", -12.5e+3`)
+	}
+	sb.WriteString(`]}`)
+	src := sb.String()
+
+	want, err := Parse(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	readers := map[string]io.Reader{
+		"one byte at a time": iotest.OneByteReader(strings.NewReader(src)),
+		"half reads":         iotest.HalfReader(strings.NewReader(src)),
+	}
+	for name, r := range readers {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseReader(r, ParseOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Error("ParseReader() tree differs from Parse()")
+			}
+		})
+	}
+
+	t.Run("read error", func(t *testing.T) {
+		errRead := errors.New("read failed")
+		r := io.MultiReader(strings.NewReader(`{"a": [1, 2`), iotest.ErrReader(errRead))
+
+		if _, err := ParseReader(r, ParseOptions{}); !errors.Is(err, errRead) {
+			t.Errorf("ParseReader() error = %v, want %v", err, errRead)
+		}
+	})
+
+	t.Run("read error after a whole document", func(t *testing.T) {
+		errRead := errors.New("read failed")
+		r := io.MultiReader(strings.NewReader(`{"a": [1, 2]}`), iotest.ErrReader(errRead))
+
+		if err := Validate(r, ParseOptions{}); !errors.Is(err, errRead) {
+			t.Errorf("Validate() error = %v, want %v", err, errRead)
+		}
+	})
+}
+
+func Test_Validate(t *testing.T) {
+	files, err := filepath.Glob("../test/*.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			buf, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, want := Parse(string(buf))
+			got := Validate(bytes.NewReader(buf), ParseOptions{})
+			if fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Errorf("Validate() error = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func Test_ParseLines(t *testing.T) {
+	src := "{\"a\": 1}\n" +
+		"\n" +
+		"[1, 2\n" +
+		"  [true]  \r\n" +
+		"{\"b\":\n" +
+		"2}\n" +
+		"[\"x\"] [\"y\"]\n" +
+		"{}"
+
+	type result struct {
+		Line int
+		Node bool
+		Err  string
+	}
+	want := []result{
+		{Line: 1, Node: true},
+		{Line: 3, Err: "3:6: unexpected end of line, expected ',' or ']' after array element"},
+		{Line: 4, Node: true},
+		{Line: 5, Err: "5:6: unexpected end of line, expected value after ':'"},
+		{Line: 6, Err: "6:2: unexpected '}', expected end of line after the document"},
+		{Line: 7, Err: "7:7: unexpected '[', expected end of line after the document"},
+		{Line: 8, Node: true},
+	}
+
+	var got []result
+	err := ParseLines(strings.NewReader(src), ParseOptions{}, func(line int, node ast.Node, err error) error {
+		r := result{Line: line, Node: node != nil}
+		if err != nil {
+			r.Err = err.Error()
+		}
+		got = append(got, r)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseLines() = %+v, want %+v", got, want)
+	}
+}
+
+func Test_ParseRecover(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		opts ParseOptions
+		want string // the partial tree, minified
+		errs []string
+	}{
+		{
+			name: "valid",
+			src:  `{"a": [1, {"b": null}]}`,
+			want: `{"a":[1,{"b":null}]}`,
+		},
+		{
+			name: "missing commas and colon",
+			src:  `{"a": 1 "b" 2, "c": [1 2]}`,
+			want: `{"a":1,"b":2,"c":[1,2]}`,
+			errs: []string{
+				`1:9: unexpected string "b", expected ',' or '}' after object member`,
+				`1:13: unexpected number 2, expected ':' after object key`,
+				`1:24: unexpected number 2, expected ',' or ']' after array element`,
+			},
+		},
+		{
+			name: "bad values are dropped",
+			src:  "{\"a\": @, \"b\": \"x\\qy\", \"c\": tru, \"d\": [1, , 3], \"e\": true}",
+			want: `{"d":[1,3],"e":true}`,
+			errs: []string{
+				`1:7: unexpected '@', expected value after ':'`,
+				`1:17: invalid escape sequence '\q' in string`,
+				`1:28: unexpected 'tru', expected value after ':'`,
+				`1:42: unexpected ',', expected value after ','`,
+			},
+		},
+		{
+			name: "bad nested values are skipped",
+			src:  `[1, x {"a": [2, 3]} y, 4]`,
+			want: `[1,4]`,
+			errs: []string{`1:5: unexpected 'x', expected value after ','`},
+		},
+		{
+			name: "unterminated string",
+			src:  "[\"abc\n, 2]",
+			want: `[2]`,
+			errs: []string{`1:6: invalid character '\n' in string`},
+		},
+		{
+			name: "missing closing brackets",
+			src:  `{"a": [1, {"b": 2], "c": [3`,
+			want: `{"a":[1,{"b":2}],"c":[3]}`,
+			errs: []string{
+				`1:18: unexpected ']', expected ',' or '}' after object member`,
+				`1:28: unexpected end of input, expected ',' or ']' after array element`,
+			},
+		},
+		{
+			name: "garbage around the document",
+			src:  `x {"a": 1} }`,
+			want: `{"a":1}`,
+			errs: []string{
+				`1:1: unexpected 'x', expected value at the start of the document`,
+				`1:12: unexpected '}', expected end of input after the document`,
+			},
+		},
+		{
+			name: "scalar document",
+			src:  `42 "x"`,
+			want: `42`,
+			errs: []string{`1:4: unexpected string "x", expected end of input after the document`},
+		},
+		{
+			name: "no document",
+			src:  `: ]`,
+			errs: []string{`1:1: unexpected ':', expected value at the start of the document`},
+		},
+		{
+			name: "error limit",
+			src:  `[@, @, @, @]`,
+			opts: ParseOptions{MaxErrors: 2},
+			want: `[]`,
+			errs: []string{
+				`1:2: unexpected '@', expected value or ']'`,
+				`1:5: unexpected '@', expected value after ','`,
+			},
+		},
+		{
+			name: "depth limit",
+			src:  `[@, [[1]], @]`,
+			opts: ParseOptions{MaxDepth: 2},
+			want: `[[]]`,
+			errs: []string{
+				`1:2: unexpected '@', expected value or ']'`,
+				`1:6: max depth exceeded, more than 2 nested arrays and objects`,
+			},
+		},
+		{
+			name: "trailing comma in jsonc",
+			src:  `{"a": [1,], "b" 2,}`,
+			opts: ParseOptions{Mode: JSONC},
+			want: `{"a":[1],"b":2}`,
+			errs: []string{`1:17: unexpected number 2, expected ':' after object key`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node, err := ParseRecover(strings.NewReader(tt.src), tt.opts)
+
+			got := ""
+			if node != nil {
+				got = format.String(node, format.Options{})
+			}
+			if got != tt.want {
+				t.Errorf("ParseRecover() = %s, want %s", got, tt.want)
+			}
+
+			var errs []string
+			if err != nil {
+				list, ok := err.(ErrorList)
+				if !ok {
+					t.Fatalf("ParseRecover() error = %v, want an ErrorList", err)
+				}
+				for _, e := range list {
+					errs = append(errs, e.Error())
+				}
+			}
+			if strings.Join(errs, "\n") != strings.Join(tt.errs, "\n") {
+				t.Errorf("ParseRecover() errors =\n%s\nwant\n%s", strings.Join(errs, "\n"), strings.Join(tt.errs, "\n"))
+			}
+		})
+	}
+
+	t.Run("test files", func(t *testing.T) {
+		files, err := filepath.Glob("../test/*.json")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, file := range files {
+			src, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want, wantErr := Parse(string(src))
+			got, err := ParseRecover(bytes.NewReader(src), ParseOptions{})
+
+			var list ErrorList
+			switch {
+			case wantErr == nil && (err != nil || !reflect.DeepEqual(got, want)):
+				t.Errorf("%s: ParseRecover() = %v, want the tree of Parse()", file, err)
+			case wantErr != nil && !errors.As(err, &list):
+				t.Errorf("%s: ParseRecover() error = %v, want an ErrorList", file, err)
+			case wantErr != nil && list[0].Error() != wantErr.Error():
+				t.Errorf("%s: first error = %v, want %v", file, list[0], wantErr)
+			}
+		}
+	})
+
+	t.Run("read error", func(t *testing.T) {
+		errRead := errors.New("read failed")
+		r := io.MultiReader(strings.NewReader(`{"a": @, "b": [1, 2`), iotest.ErrReader(errRead))
+
+		node, err := ParseRecover(r, ParseOptions{})
+		if !errors.Is(err, errRead) {
+			t.Errorf("ParseRecover() error = %v, want %v", err, errRead)
+		}
+		if node == nil {
+			t.Error("ParseRecover() = nil, want the partial tree")
+		}
+	})
+}
+func Test_UnmarshalEncodingJSON(t *testing.T) {
+	files, err := filepath.Glob("../test/pass*.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			buf, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got, want any
+			if err := Unmarshal(buf, &got); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			if err := json.Unmarshal(buf, &want); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Unmarshal() = %v, want %v", got, want)
+			}
+		})
+	}
+
+	// the members of pass1 with the same name but for the case
+	type pass1 struct {
+		Integer   int64             `json:"integer"`
+		Real      float64           `json:"real"`
+		LowerE    float64           `json:"e"`
+		UpperE    float64           `json:"E"`
+		Empty     float64           `json:""`
+		Zero      *int              `json:"zero"`
+		Controls  string            `json:"controls"`
+		Alpha     string            `json:"ALPHA"`
+		Hex       string            `json:"hex"`
+		Array     []int             `json:" s p a c e d "`
+		Compact   [3]int            `json:"compact"`
+		Object    map[string]string `json:"object"`
+		Address   string
+		URL       string `json:"url"`
+		Quotes    string `json:"quotes"`
+		Ignored   string `json:"-"`
+		unexposed string
+	}
+
+	buf, err := os.ReadFile("../test/pass1.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the other elements do not fit, both go on with the rest of the array
+	var got, want []pass1
+	if err := Unmarshal(buf, &got); err == nil {
+		t.Errorf("Unmarshal() error = nil, want an error")
+	}
+	if err := json.Unmarshal(buf, &want); err == nil {
+		t.Fatal("json.Unmarshal() error = nil")
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Unmarshal() = %+v, want %+v", got, want)
+	}
+	if len(got) < 9 || got[8].Integer != 1234567890 || got[8].UpperE != 1.23456789e34 {
+		t.Errorf("Unmarshal() did not decode the object of pass1: %+v", got)
+	}
+}
+
+type celsius float64
+
+func (c *celsius) UnmarshalText(text []byte) error {
+	s, ok := strings.CutSuffix(string(text), "C")
+	if !ok {
+		return errors.New("missing unit")
+	}
+
+	f, err := strconv.ParseFloat(s, 64)
+	*c = celsius(f)
+
+	return err
+}
+
+type Base struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type Sensor struct {
+	Base
+	Name     string             `json:"label"`
+	Temp     celsius            `json:"temp"`
+	Max      *celsius           `json:"max"`
+	Count    int                `json:"count,string"`
+	Enabled  bool               `json:"enabled,string"`
+	Tags     []string           `json:"tags"`
+	Readings map[int]float64    `json:"readings"`
+	Raw      []byte             `json:"raw"`
+	Extra    any                `json:"extra"`
+	Limits   map[string]uint8   `json:"limits"`
+	Labels   map[celsius]string `json:"labels"`
+}
+
+// point is written [x, y]
+type point struct {
+	X, Y int
+}
+
+func (p *point) UnmarshalJSON(data []byte) error {
+	var xy [2]int
+	if err := json.Unmarshal(data, &xy); err != nil {
+		return err
+	}
+	p.X, p.Y = xy[0], xy[1]
+
+	return nil
+}
+
+type Shape struct {
+	Kind   string          `json:"kind"`
+	Origin point           `json:"origin"`
+	End    *point          `json:"end"`
+	Props  json.RawMessage `json:"props"`
+}
+
+func Test_Unmarshal(t *testing.T) {
+	max := celsius(80)
+
+	tests := []struct {
+		name     string
+		src      string
+		v        any
+		want     any
+		wantErr  string
+		wantPath string
+	}{
+		{
+			name: "struct",
+			src: `{"id": 7, "name": "attic", "label": "roof", "temp": "21.5C", "max": "80C", "count": "12", "enabled": "true",
+				"tags": ["a", "b"], "readings": {"1": 1.5, "-2": 2}, "raw": "aGk=", "extra": {"a": [1, null]}, "unknown": 1}`,
+			v: &Sensor{},
+			want: &Sensor{
+				Base:     Base{ID: 7, Name: "attic"},
+				Name:     "roof",
+				Temp:     21.5,
+				Max:      &max,
+				Count:    12,
+				Enabled:  true,
+				Tags:     []string{"a", "b"},
+				Readings: map[int]float64{1: 1.5, -2: 2},
+				Raw:      []byte("hi"),
+				Extra:    map[string]any{"a": []any{1.0, nil}},
+			},
+		},
+		{
+			name: "case insensitive names",
+			src:  `{"ID": 1, "NAME": "x"}`,
+			v:    &Base{},
+			want: &Base{ID: 1, Name: "x"},
+		},
+		{
+			name: "null leaves values alone",
+			src:  `{"id": null, "tags": null, "max": null}`,
+			v:    &Sensor{Base: Base{ID: 3}, Tags: []string{"a"}, Max: &max},
+			want: &Sensor{Base: Base{ID: 3}},
+		},
+		{
+			name: "existing map",
+			src:  `{"b": 2}`,
+			v:    &map[string]int{"a": 1},
+			want: &map[string]int{"a": 1, "b": 2},
+		},
+		{
+			name: "short array",
+			src:  `[1, 2]`,
+			v:    &[3]int{7, 8, 9},
+			want: &[3]int{1, 2, 0},
+		},
+		{
+			name: "long array",
+			src:  `[1, 2, 3, 4]`,
+			v:    &[2]int{},
+			want: &[2]int{1, 2},
+		},
+		{
+			name: "pointer in interface",
+			src:  `{"id": 4}`,
+			v:    func() any { var v any = &Base{}; return &v }(),
+			want: func() any { var v any = &Base{ID: 4}; return &v }(),
+		},
+		{
+			name: "json unmarshaler",
+			src:  `{"kind": "line", "origin": [1, 2], "end": [3,4], "props": {"color": [255, 0, 0], "dash": null} }`,
+			v:    &Shape{},
+			want: &Shape{
+				Kind:   "line",
+				Origin: point{1, 2},
+				End:    &point{3, 4},
+				Props:  json.RawMessage(`{"color": [255, 0, 0], "dash": null}`),
+			},
+		},
+		{
+			name: "raw null",
+			src:  `{"props": null, "end": null}`,
+			v:    &Shape{End: &point{1, 2}},
+			want: &Shape{Props: json.RawMessage(`null`)},
+		},
+		{
+			name: "raw values",
+			src:  `[1.5e3, "a\"b", true, [], {"a": {}}]`,
+			v:    &[]json.RawMessage{},
+			want: &[]json.RawMessage{
+				json.RawMessage(`1.5e3`),
+				json.RawMessage(`"a\"b"`),
+				json.RawMessage(`true`),
+				json.RawMessage(`[]`),
+				json.RawMessage(`{"a": {}}`),
+			},
+		},
+		{
+			name:    "json unmarshaler error",
+			src:     `{"origin": "1,2"}`,
+			v:       &Shape{},
+			wantErr: "json: cannot unmarshal string into Go value of type [2]int",
+		},
+		{
+			name:     "wrong type",
+			src:      `{"tags": ["a", 2]}`,
+			v:        &Sensor{},
+			wantErr:  "1:16: cannot unmarshal number into Go value of type string at $.tags[1]",
+			wantPath: "$.tags[1]",
+		},
+		{
+			name:     "overflow",
+			src:      `{"limits": {"max load": 300}}`,
+			v:        &Sensor{},
+			wantErr:  `1:25: cannot unmarshal number 300 into Go value of type uint8 at $.limits["max load"]`,
+			wantPath: `$.limits["max load"]`,
+		},
+		{
+			name:     "fraction into an integer",
+			src:      `[[1], [2.5]]`,
+			v:        &[][]int{},
+			wantErr:  "1:8: cannot unmarshal number 2.5 into Go value of type int at $[1][0]",
+			wantPath: "$[1][0]",
+		},
+		{
+			name:     "text unmarshaler",
+			src:      `{"temp": "21.5F"}`,
+			v:        &Sensor{},
+			wantErr:  `1:10: cannot unmarshal string "21.5F" into Go value of type parser.celsius at $.temp`,
+			wantPath: "$.temp",
+		},
+		{
+			name:     "map key",
+			src:      `{"labels": {"20": "warm"}}`,
+			v:        &Sensor{},
+			wantErr:  `1:13: cannot unmarshal string "20" into Go value of type parser.celsius at $.labels["20"]`,
+			wantPath: `$.labels["20"]`,
+		},
+		{
+			name:     "quoted field",
+			src:      `{"count": 12}`,
+			v:        &Sensor{},
+			wantErr:  "1:11: cannot unmarshal number into Go value of type int at $.count",
+			wantPath: "$.count",
+		},
+		{
+			name:     "first error",
+			src:      `[{"id": "1"}, {"id": true}]`,
+			v:        &[]Base{},
+			wantErr:  `1:9: cannot unmarshal string into Go value of type int at $[0].id`,
+			wantPath: "$[0].id",
+		},
+		{
+			name:    "syntax error",
+			src:     `{"id": 1,}`,
+			v:       &Base{},
+			wantErr: "1:10: unexpected '}', expected string key after ','",
+		},
+		{
+			name:    "non pointer",
+			src:     `{}`,
+			v:       Base{},
+			wantErr: "Unmarshal(non-pointer parser.Base)",
+		},
+		{
+			name:    "nil pointer",
+			src:     `{}`,
+			v:       (*Base)(nil),
+			wantErr: "Unmarshal(nil *parser.Base)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Unmarshal([]byte(tt.src), tt.v)
+
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("Unmarshal() error = %v, want %v", err, tt.wantErr)
+				}
+
+				var typeErr *UnmarshalTypeError
+				if errors.As(err, &typeErr) && typeErr.Path != tt.wantPath {
+					t.Errorf("Unmarshal() path = %v, want %v", typeErr.Path, tt.wantPath)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			if !reflect.DeepEqual(tt.v, tt.want) {
+				t.Errorf("Unmarshal() = %+v, want %+v", tt.v, tt.want)
+			}
+		})
+	}
+}
+
+func Test_Query(t *testing.T) {
+	const store = `{
+		"store": {
+			"book": [
+				{"category": "reference", "author": "Nigel Rees", "title": "Sayings of the Century", "price": 8.95},
+				{"category": "fiction", "author": "Evelyn Waugh", "title": "Sword of Honour", "price": 12.99},
+				{"category": "fiction", "author": "Herman Melville", "title": "Moby Dick", "isbn": "0-553-21311-3", "price": 8.99},
+				{"category": "fiction", "author": "J. R. R. Tolkien", "title": "The Lord of the Rings", "isbn": "0-395-19395-8", "price": 22.99}
+			],
+			"bicycle": {"color": "red", "price": 19.95}
+		},
+		"expensive": 10,
+		"odd key": [0, 1, 2, 3, 4, 5]
+	}`
+
+	root, err := Parse(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		expr    string
+		want    string
+		wantErr string
+	}{
+		{name: "root", expr: "$.expensive", want: `[10]`},
+		{name: "child", expr: "$.store.bicycle.color", want: `["red"]`},
+		{name: "bracket", expr: `$['store']["bicycle"]['color']`, want: `["red"]`},
+		{name: "quoted key", expr: `$['odd key'][1]`, want: `[1]`},
+		{name: "missing", expr: "$.store.car", want: `[]`},
+		{name: "wildcard", expr: "$.store.book[*].author", want: `["Nigel Rees","Evelyn Waugh","Herman Melville","J. R. R. Tolkien"]`},
+		{name: "dot wildcard", expr: "$.store.bicycle.*", want: `["red",19.95]`},
+		{name: "negative index", expr: "$.store.book[-1].title", want: `["The Lord of the Rings"]`},
+		{name: "union", expr: "$.store.book[0,2].price", want: `[8.95,8.99]`},
+		{name: "slice", expr: "$['odd key'][0:3]", want: `[0,1,2]`},
+		{name: "slice from the end", expr: "$['odd key'][-2:]", want: `[4,5]`},
+		{name: "slice with step", expr: "$['odd key'][::2]", want: `[0,2,4]`},
+		{name: "reversed slice", expr: "$['odd key'][::-2]", want: `[5,3,1]`},
+		{name: "slice out of range", expr: "$['odd key'][4:100]", want: `[4,5]`},
+		{name: "descendants", expr: "$..author", want: `["Nigel Rees","Evelyn Waugh","Herman Melville","J. R. R. Tolkien"]`},
+		{name: "descendant prices", expr: "$.store..price", want: `[8.95,12.99,8.99,22.99,19.95]`},
+		{name: "descendant bracket", expr: "$..book[2].title", want: `["Moby Dick"]`},
+		{name: "filter", expr: "$.store.book[?(@.price < 10)].title", want: `["Sayings of the Century","Moby Dick"]`},
+		{name: "filter without parentheses", expr: "$.store.book[?@.price >= 12.99].price", want: `[12.99,22.99]`},
+		{name: "filter on the root", expr: "$.store.book[?(@.price > $.expensive)].price", want: `[12.99,22.99]`},
+		{name: "existence", expr: "$..book[?(@.isbn)].title", want: `["Moby Dick","The Lord of the Rings"]`},
+		{name: "negation", expr: "$..book[?(!@.isbn)].title", want: `["Sayings of the Century","Sword of Honour"]`},
+		{name: "logical operators", expr: `$..book[?(@.category == 'fiction' && (@.price < 10 || @.author == "J. R. R. Tolkien"))].title`, want: `["Moby Dick","The Lord of the Rings"]`},
+		{name: "not equal to a missing member", expr: "$..book[?(@.isbn != '0-553-21311-3')].price", want: `[8.95,12.99,22.99]`},
+		{name: "current value", expr: "$['odd key'][?(@ >= 4)]", want: `[4,5]`},
+		{name: "number equality", expr: "$[?(@ == 1e1)]", want: `[10]`},
+		{name: "blanks", expr: " $.store [ 'bicycle' ] ", want: `[{"color":"red","price":19.95}]`},
+
+		{name: "no root", expr: "store", wantErr: "column 1: expected '$' at the start of the query"},
+		{name: "trailing text", expr: "$.store)", wantErr: "column 8: unexpected ')', expected '.', '..' or '['"},
+		{name: "missing name", expr: "$.", wantErr: "column 3: unexpected end of query, expected member name or '*' after '.'"},
+		{name: "unclosed bracket", expr: "$.store[0", wantErr: "column 10: unexpected end of query, expected ',' or ']' after selector"},
+		{name: "unterminated string", expr: "$['store]", wantErr: "column 3: unterminated string"},
+		{name: "bad selector", expr: "$[store]", wantErr: "column 3: unexpected 's', expected selector"},
+		{name: "unclosed filter", expr: "$[?(@.a == 1]", wantErr: "column 13: unexpected ']', expected ')'"},
+		{name: "literal alone", expr: "$[?(1)]", wantErr: "column 6: unexpected ')', expected comparison operator after literal"},
+		{name: "largest step", expr: "$['odd key'][1::9007199254740991]", want: `[1]`},
+		{name: "largest negative step", expr: "$['odd key'][-1::-9007199254740991]", want: `[5]`},
+		{name: "step out of range", expr: "$['odd key'][1::9223372036854775807]", wantErr: `column 17: invalid integer "9223372036854775807"`},
+		{name: "index out of range", expr: "$['odd key'][-9007199254740992]", wantErr: `column 14: invalid integer "-9007199254740992"`},
+		{name: "integer overflow", expr: "$.store[99999999999999999999]", wantErr: `column 9: invalid integer "99999999999999999999"`},
+		{name: "non singular comparison", expr: "$[?(@.a[*] == 1)]", wantErr: "column 5: compared path must select a single value, with names and indexes only"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodes, err := Query(root, tt.expr)
+
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("Query() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Query() error = %v", err)
+			}
+
+			got := format.String(&ast.Array{Elements: nodes}, format.Options{})
+			if got != tt.want {
+				t.Errorf("Query() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
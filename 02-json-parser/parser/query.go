@@ -0,0 +1,873 @@
+package parser
+
+import (
+	"fmt"
+	"jp/ast"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// QueryError is a malformed JSONPath expression
+type QueryError struct {
+	Offset int // offset of the error in the expression, in bytes
+	Msg    string
+}
+
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("column %d: %s", e.Offset+1, e.Msg)
+}
+
+// Query returns the values of node selected by the JSONPath expression
+// expr, in document order. It supports:
+//
+//	$               the root
+//	.name ['name']  the member of an object
+//	.* [*]          all members or elements
+//	[0] [-1]        the element of an array, from the end when negative
+//	[0:3] [::2]     a slice of an array, [start:end:step]
+//	[0,2] ['a','b'] several selectors
+//	..name ..*      the descendants, at any depth
+//	[?(@.price < 10)]
+//	                the members or elements matching a filter, made of
+//	                comparisons (== != < <= > >=) of paths and literals,
+//	                existence tests like @.isbn, !, && and ||
+//
+// A malformed expression returns a *QueryError.
+func Query(node ast.Node, expr string) ([]ast.Node, error) {
+	q := queryParser{expr: expr}
+
+	path, err := q.parse()
+	if err != nil {
+		return nil, err
+	}
+
+	return path.eval(node, node), nil
+}
+
+// jsonPath is a query, the segments are applied in order to the values
+// selected by the previous ones
+type jsonPath []segment
+
+// segment selects values from a value, or from it and its descendants
+type segment struct {
+	selectors  []selector
+	descendant bool
+}
+
+// selector selects values from an object or an array, root is the root of
+// the document for the filters
+type selector interface {
+	selectFrom(node, root ast.Node, out []ast.Node) []ast.Node
+}
+
+type nameSelector string
+
+type wildcardSelector struct{}
+
+type indexSelector int
+
+type sliceSelector struct {
+	start, end *int
+	step       int
+}
+
+type filterSelector struct {
+	expr filterExpr
+}
+
+func (p jsonPath) eval(node, root ast.Node) []ast.Node {
+	nodes := []ast.Node{node}
+
+	for _, seg := range p {
+		var next []ast.Node
+		for _, n := range nodes {
+			if seg.descendant {
+				next = seg.selectDescendants(n, root, next)
+			} else {
+				next = seg.selectFrom(n, root, next)
+			}
+		}
+		nodes = next
+	}
+
+	return nodes
+}
+
+// singular reports whether the path selects at most one value
+func (p jsonPath) singular() bool {
+	for _, seg := range p {
+		if seg.descendant || len(seg.selectors) != 1 {
+			return false
+		}
+
+		switch seg.selectors[0].(type) {
+		case nameSelector, indexSelector:
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+func (seg segment) selectFrom(node, root ast.Node, out []ast.Node) []ast.Node {
+	for _, s := range seg.selectors {
+		out = s.selectFrom(node, root, out)
+	}
+
+	return out
+}
+
+// selectDescendants applies the selectors to node and to its descendants,
+// in document order
+func (seg segment) selectDescendants(node, root ast.Node, out []ast.Node) []ast.Node {
+	out = seg.selectFrom(node, root, out)
+
+	switch node := node.(type) {
+	case *ast.Object:
+		for _, m := range node.Members {
+			out = seg.selectDescendants(m.Value, root, out)
+		}
+	case *ast.Array:
+		for _, elem := range node.Elements {
+			out = seg.selectDescendants(elem, root, out)
+		}
+	}
+
+	return out
+}
+
+func (s nameSelector) selectFrom(node, _ ast.Node, out []ast.Node) []ast.Node {
+	if obj, ok := node.(*ast.Object); ok {
+		if value, ok := obj.Get(string(s)); ok {
+			out = append(out, value)
+		}
+	}
+
+	return out
+}
+
+func (wildcardSelector) selectFrom(node, _ ast.Node, out []ast.Node) []ast.Node {
+	switch node := node.(type) {
+	case *ast.Object:
+		for _, m := range node.Members {
+			out = append(out, m.Value)
+		}
+	case *ast.Array:
+		out = append(out, node.Elements...)
+	}
+
+	return out
+}
+
+func (s indexSelector) selectFrom(node, _ ast.Node, out []ast.Node) []ast.Node {
+	arr, ok := node.(*ast.Array)
+	if !ok {
+		return out
+	}
+
+	i := int(s)
+	if i < 0 {
+		i += arr.Len()
+	}
+	if elem, ok := arr.Index(i); ok {
+		out = append(out, elem)
+	}
+
+	return out
+}
+
+// selectFrom selects the elements like a Python slice: the bounds count
+// from the end when negative and a negative step walks the array backwards
+func (s sliceSelector) selectFrom(node, _ ast.Node, out []ast.Node) []ast.Node {
+	arr, ok := node.(*ast.Array)
+	if !ok || s.step == 0 {
+		return out
+	}
+
+	n := arr.Len()
+	bound := func(i *int, def int) int {
+		if i == nil {
+			return def
+		}
+		if *i < 0 {
+			return *i + n
+		}
+		return *i
+	}
+
+	if s.step > 0 {
+		start := min(max(bound(s.start, 0), 0), n)
+		end := min(max(bound(s.end, n), 0), n)
+		for i := start; i < end; i += s.step {
+			out = append(out, arr.Elements[i])
+			// the next index is past the end, i + s.step may overflow
+			if i >= end-s.step {
+				break
+			}
+		}
+		return out
+	}
+
+	start := min(max(bound(s.start, n-1), -1), n-1)
+	end := min(max(bound(s.end, -n-1), -1), n-1)
+	for i := start; i > end; i += s.step {
+		out = append(out, arr.Elements[i])
+		if i <= end-s.step {
+			break
+		}
+	}
+
+	return out
+}
+
+func (s filterSelector) selectFrom(node, root ast.Node, out []ast.Node) []ast.Node {
+	var children []ast.Node
+	switch node := node.(type) {
+	case *ast.Object:
+		for _, m := range node.Members {
+			children = append(children, m.Value)
+		}
+	case *ast.Array:
+		children = node.Elements
+	}
+
+	for _, child := range children {
+		if s.expr.test(child, root) {
+			out = append(out, child)
+		}
+	}
+
+	return out
+}
+
+// filterExpr is a condition of a filter, tested on a member or an element
+type filterExpr interface {
+	test(current, root ast.Node) bool
+}
+
+type orExpr struct{ left, right filterExpr }
+
+type andExpr struct{ left, right filterExpr }
+
+type notExpr struct{ expr filterExpr }
+
+// existsExpr is true when the path selects a value
+type existsExpr struct{ operand pathOperand }
+
+type compareExpr struct {
+	op          string
+	left, right operand
+}
+
+func (e orExpr) test(current, root ast.Node) bool {
+	return e.left.test(current, root) || e.right.test(current, root)
+}
+
+func (e andExpr) test(current, root ast.Node) bool {
+	return e.left.test(current, root) && e.right.test(current, root)
+}
+
+func (e notExpr) test(current, root ast.Node) bool {
+	return !e.expr.test(current, root)
+}
+
+func (e existsExpr) test(current, root ast.Node) bool {
+	return len(e.operand.nodes(current, root)) > 0
+}
+
+// test compares the values of the operands, a path selecting nothing is
+// only equal to another one selecting nothing
+func (e compareExpr) test(current, root ast.Node) bool {
+	left, right := e.left.value(current, root), e.right.value(current, root)
+
+	switch e.op {
+	case "==":
+		return equalValues(left, right)
+	case "!=":
+		return !equalValues(left, right)
+	case "<":
+		return lessValues(left, right)
+	case "<=":
+		return lessValues(left, right) || equalValues(left, right) && left != nil
+	case ">":
+		return lessValues(right, left)
+	case ">=":
+		return lessValues(right, left) || equalValues(left, right) && left != nil
+	}
+
+	return false
+}
+
+// equalValues is ast.Equal where nil, a path selecting nothing, is only
+// equal to nil
+func equalValues(a, b ast.Node) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+
+	return ast.Equal(a, b)
+}
+
+// operand is a side of a comparison, its value is nil when it is a path
+// selecting nothing
+type operand interface {
+	value(current, root ast.Node) ast.Node
+}
+
+// pathOperand is a path from the current value, @, or from the root, $
+type pathOperand struct {
+	path jsonPath
+	root bool
+}
+
+type literalOperand struct {
+	node ast.Node
+}
+
+func (o pathOperand) nodes(current, root ast.Node) []ast.Node {
+	if o.root {
+		return o.path.eval(root, root)
+	}
+
+	return o.path.eval(current, root)
+}
+
+func (o pathOperand) value(current, root ast.Node) ast.Node {
+	nodes := o.nodes(current, root)
+	if len(nodes) == 0 {
+		return nil
+	}
+
+	return nodes[0]
+}
+
+func (o literalOperand) value(_, _ ast.Node) ast.Node {
+	return o.node
+}
+
+// lessValues reports whether a is less than b, only numbers and strings
+// are ordered
+func lessValues(a, b ast.Node) bool {
+	switch a := a.(type) {
+	case *ast.Number:
+		b, ok := b.(*ast.Number)
+		return ok && ast.Compare(a, b) < 0
+
+	case *ast.String:
+		b, ok := b.(*ast.String)
+		return ok && a.Value < b.Value
+	}
+
+	return false
+}
+
+// queryParser parses a JSONPath expression
+type queryParser struct {
+	expr string
+	pos  int // offset of the next byte
+}
+
+func (q *queryParser) parse() (jsonPath, error) {
+	q.skipBlanks()
+	if !q.consume("$") {
+		return nil, q.errorf("expected '$' at the start of the query")
+	}
+
+	path, err := q.parseSegments()
+	if err != nil {
+		return nil, err
+	}
+
+	q.skipBlanks()
+	if q.pos < len(q.expr) {
+		return nil, q.unexpected("'.', '..' or '['")
+	}
+
+	return path, nil
+}
+
+// parseSegments parses the segments following $ or @
+func (q *queryParser) parseSegments() (jsonPath, error) {
+	var path jsonPath
+
+	for {
+		// blanks are allowed between segments
+		save := q.pos
+		q.skipBlanks()
+
+		switch {
+		case q.consume(".."):
+			seg, err := q.parseDotSegment(true)
+			if err != nil {
+				return nil, err
+			}
+			path = append(path, seg)
+
+		case q.consume("."):
+			seg, err := q.parseDotSegment(false)
+			if err != nil {
+				return nil, err
+			}
+			path = append(path, seg)
+
+		case q.peek() == '[':
+			selectors, err := q.parseBracket()
+			if err != nil {
+				return nil, err
+			}
+			path = append(path, segment{selectors: selectors})
+
+		default:
+			q.pos = save
+			return path, nil
+		}
+	}
+}
+
+// parseDotSegment parses what follows . or .., a name, * or for .. a
+// bracket
+func (q *queryParser) parseDotSegment(descendant bool) (segment, error) {
+	seg := segment{descendant: descendant}
+
+	switch {
+	case q.consume("*"):
+		seg.selectors = []selector{wildcardSelector{}}
+
+	case descendant && q.peek() == '[':
+		selectors, err := q.parseBracket()
+		if err != nil {
+			return seg, err
+		}
+		seg.selectors = selectors
+
+	default:
+		start := q.pos
+		for q.pos < len(q.expr) && isNameChar(q.expr[q.pos], q.pos == start) {
+			q.pos++
+		}
+		if q.pos == start {
+			if descendant {
+				return seg, q.unexpected("member name, '*' or '[' after '..'")
+			}
+			return seg, q.unexpected("member name or '*' after '.'")
+		}
+		seg.selectors = []selector{nameSelector(q.expr[start:q.pos])}
+	}
+
+	return seg, nil
+}
+
+// parseBracket parses [selector, ...]
+func (q *queryParser) parseBracket() ([]selector, error) {
+	q.pos++ // [
+
+	var selectors []selector
+	for {
+		q.skipBlanks()
+
+		s, err := q.parseSelector()
+		if err != nil {
+			return nil, err
+		}
+		selectors = append(selectors, s)
+
+		q.skipBlanks()
+		switch {
+		case q.consume(","):
+		case q.consume("]"):
+			return selectors, nil
+		default:
+			return nil, q.unexpected("',' or ']' after selector")
+		}
+	}
+}
+
+func (q *queryParser) parseSelector() (selector, error) {
+	switch ch := q.peek(); {
+	case ch == '\'' || ch == '"':
+		name, err := q.parseString()
+		if err != nil {
+			return nil, err
+		}
+		return nameSelector(name), nil
+
+	case ch == '*':
+		q.pos++
+		return wildcardSelector{}, nil
+
+	case ch == '?':
+		q.pos++
+		expr, err := q.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return filterSelector{expr: expr}, nil
+
+	case ch == ':' || ch == '-' || '0' <= ch && ch <= '9':
+		return q.parseIndexOrSlice()
+	}
+
+	return nil, q.unexpected("selector")
+}
+
+// parseIndexOrSlice parses an index or start:end:step, where every part
+// is optional
+func (q *queryParser) parseIndexOrSlice() (selector, error) {
+	var parts [3]*int
+
+	for i := 0; i < 3; i++ {
+		q.skipBlanks()
+		if ch := q.peek(); ch == '-' || '0' <= ch && ch <= '9' {
+			n, err := q.parseInt()
+			if err != nil {
+				return nil, err
+			}
+			parts[i] = &n
+		}
+
+		q.skipBlanks()
+		if i == 2 || !q.consume(":") {
+			if i == 0 {
+				if parts[0] == nil {
+					return nil, q.unexpected("index or slice")
+				}
+				return indexSelector(*parts[0]), nil
+			}
+			break
+		}
+	}
+
+	s := sliceSelector{start: parts[0], end: parts[1], step: 1}
+	if parts[2] != nil {
+		s.step = *parts[2]
+	}
+
+	return s, nil
+}
+
+// maxInt is the largest index or step, the integers of RFC 9535 are those
+// exact in a float64
+const maxInt = 1<<53 - 1
+
+// parseInt parses an index or a step, within ±maxInt
+func (q *queryParser) parseInt() (int, error) {
+	start := q.pos
+	if q.peek() == '-' {
+		q.pos++
+	}
+	for q.pos < len(q.expr) && '0' <= q.expr[q.pos] && q.expr[q.pos] <= '9' {
+		q.pos++
+	}
+
+	n, err := strconv.Atoi(q.expr[start:q.pos])
+	if err != nil || n < -maxInt || n > maxInt {
+		// the whole literal, or the char where one was expected
+		lit := q.expr[start:max(q.pos, start+1)]
+		q.pos = start
+		return 0, q.errorf("invalid integer %q", lit)
+	}
+
+	return n, nil
+}
+
+// parseOr parses a filter expression, && binds tighter than ||
+func (q *queryParser) parseOr() (filterExpr, error) {
+	left, err := q.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		q.skipBlanks()
+		if !q.consume("||") {
+			return left, nil
+		}
+
+		right, err := q.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orExpr{left: left, right: right}
+	}
+}
+
+func (q *queryParser) parseAnd() (filterExpr, error) {
+	left, err := q.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		q.skipBlanks()
+		if !q.consume("&&") {
+			return left, nil
+		}
+
+		right, err := q.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = andExpr{left: left, right: right}
+	}
+}
+
+func (q *queryParser) parseUnary() (filterExpr, error) {
+	q.skipBlanks()
+
+	switch {
+	case q.consume("!"):
+		expr, err := q.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notExpr{expr: expr}, nil
+
+	case q.consume("("):
+		expr, err := q.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		q.skipBlanks()
+		if !q.consume(")") {
+			return nil, q.unexpected("')'")
+		}
+		return expr, nil
+	}
+
+	return q.parseComparison()
+}
+
+var comparisonOps = []string{"==", "!=", "<=", ">=", "<", ">"}
+
+// parseComparison parses a comparison, or a path alone for an existence
+// test
+func (q *queryParser) parseComparison() (filterExpr, error) {
+	start := q.pos
+
+	left, err := q.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	q.skipBlanks()
+	op := ""
+	for _, o := range comparisonOps {
+		if q.consume(o) {
+			op = o
+			break
+		}
+	}
+
+	if op == "" {
+		path, isPath := left.(pathOperand)
+		if !isPath {
+			return nil, q.unexpected("comparison operator after literal")
+		}
+		return existsExpr{operand: path}, nil
+	}
+
+	q.skipBlanks()
+	rightStart := q.pos
+
+	right, err := q.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	// a compared path must select one value at most
+	for _, side := range []struct {
+		operand
+		start int
+	}{{left, start}, {right, rightStart}} {
+		if path, isPath := side.operand.(pathOperand); isPath && !path.path.singular() {
+			return nil, &QueryError{Offset: side.start, Msg: "compared path must select a single value, with names and indexes only"}
+		}
+	}
+
+	return compareExpr{op: op, left: left, right: right}, nil
+}
+
+func (q *queryParser) parseOperand() (operand, error) {
+	switch ch := q.peek(); {
+	case ch == '@' || ch == '$':
+		q.pos++
+		path, err := q.parseSegments()
+		if err != nil {
+			return nil, err
+		}
+		return pathOperand{path: path, root: ch == '$'}, nil
+
+	case ch == '\'' || ch == '"':
+		s, err := q.parseString()
+		if err != nil {
+			return nil, err
+		}
+		return literalOperand{node: &ast.String{Value: s}}, nil
+
+	case ch == '-' || '0' <= ch && ch <= '9':
+		start := q.pos
+		for q.pos < len(q.expr) && strings.IndexByte("0123456789+-.eE", q.expr[q.pos]) >= 0 {
+			q.pos++
+		}
+		lit := q.expr[start:q.pos]
+		if _, err := strconv.ParseFloat(lit, 64); err != nil {
+			q.pos = start
+			return nil, q.errorf("invalid number %q", lit)
+		}
+		return literalOperand{node: &ast.Number{Literal: lit}}, nil
+	}
+
+	switch {
+	case q.consume("true"):
+		return literalOperand{node: &ast.Bool{Value: true}}, nil
+	case q.consume("false"):
+		return literalOperand{node: &ast.Bool{Value: false}}, nil
+	case q.consume("null"):
+		return literalOperand{node: &ast.Null{}}, nil
+	}
+
+	return nil, q.unexpected("'@', '$' or literal")
+}
+
+// parseString parses a string quoted with ' or ", with the escape
+// sequences of JSON plus \'
+func (q *queryParser) parseString() (string, error) {
+	start := q.pos
+	quote := q.expr[q.pos]
+	q.pos++
+
+	var b strings.Builder
+	for {
+		if q.pos >= len(q.expr) {
+			return "", &QueryError{Offset: start, Msg: "unterminated string"}
+		}
+
+		ch := q.expr[q.pos]
+		switch {
+		case ch == quote:
+			q.pos++
+			return b.String(), nil
+
+		case ch == '\\':
+			r, size := q.unescape()
+			if size == 0 {
+				return "", q.errorf("invalid escape sequence in string")
+			}
+			b.WriteRune(r)
+			q.pos += size
+
+		default:
+			b.WriteByte(ch)
+			q.pos++
+		}
+	}
+}
+
+// unescape decodes the escape sequence at the current backslash, size is 0
+// when it is invalid
+func (q *queryParser) unescape() (r rune, size int) {
+	seq := q.expr[q.pos:]
+	if len(seq) < 2 {
+		return 0, 0
+	}
+
+	switch seq[1] {
+	case '"', '\'', '\\', '/':
+		return rune(seq[1]), 2
+	case 'b':
+		return '\b', 2
+	case 'f':
+		return '\f', 2
+	case 'n':
+		return '\n', 2
+	case 'r':
+		return '\r', 2
+	case 't':
+		return '\t', 2
+	case 'u':
+		r, ok := hex4([]byte(seq[2:]))
+		if !ok {
+			return 0, 0
+		}
+		if utf16.IsSurrogate(r) && len(seq) >= 12 && seq[6:8] == `\u` {
+			if low, ok := hex4([]byte(seq[8:])); ok {
+				if dec := utf16.DecodeRune(r, low); dec != utf8.RuneError {
+					return dec, 12
+				}
+			}
+		}
+		if utf16.IsSurrogate(r) {
+			return utf8.RuneError, 6
+		}
+		return r, 6
+	}
+
+	return 0, 0
+}
+
+// hex4 decodes the 4 hexadecimal digits at the start of b
+func hex4(b []byte) (rune, bool) {
+	if len(b) < 4 {
+		return 0, false
+	}
+
+	n, err := strconv.ParseUint(string(b[:4]), 16, 16)
+
+	return rune(n), err == nil
+}
+
+func (q *queryParser) peek() byte {
+	if q.pos >= len(q.expr) {
+		return 0
+	}
+
+	return q.expr[q.pos]
+}
+
+// consume moves past s when the expression continues with it
+func (q *queryParser) consume(s string) bool {
+	if !strings.HasPrefix(q.expr[q.pos:], s) {
+		return false
+	}
+	q.pos += len(s)
+
+	return true
+}
+
+func (q *queryParser) skipBlanks() {
+	for q.pos < len(q.expr) && strings.IndexByte(" \t\n\r", q.expr[q.pos]) >= 0 {
+		q.pos++
+	}
+}
+
+func (q *queryParser) errorf(format string, args ...any) error {
+	return &QueryError{Offset: q.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// unexpected returns the error for the text at the current offset, found
+// where expected was
+func (q *queryParser) unexpected(expected string) error {
+	if q.pos >= len(q.expr) {
+		return q.errorf("unexpected end of query, expected %s", expected)
+	}
+
+	r, _ := utf8.DecodeRuneInString(q.expr[q.pos:])
+
+	return q.errorf("unexpected '%c', expected %s", r, expected)
+}
+
+// isNameChar reports whether ch can be part of a member name written after
+// a dot, a digit can not start it
+func isNameChar(ch byte, first bool) bool {
+	switch {
+	case 'a' <= ch && ch <= 'z', 'A' <= ch && ch <= 'Z', ch == '_', ch >= utf8.RuneSelf:
+		return true
+	case '0' <= ch && ch <= '9', ch == '-':
+		return !first
+	}
+
+	return false
+}
@@ -0,0 +1,125 @@
+package patch
+
+import (
+	"jp/ast"
+	"jp/format"
+	"strconv"
+)
+
+// Change is a difference between two documents, at the JSON Pointer Path.
+// Old is nil for an added value and New is nil for a removed one.
+type Change struct {
+	Path string
+	Old  ast.Node
+	New  ast.Node
+}
+
+// String returns the change as a line of a diff, like ~ /version: 1 -> 2,
+// with + for an added value and - for a removed one
+func (c Change) String() string {
+	path := c.Path
+	if path == "" {
+		path = "(root)"
+	}
+
+	switch {
+	case c.Old == nil:
+		return "+ " + path + ": " + format.String(c.New, format.Options{})
+	case c.New == nil:
+		return "- " + path + ": " + format.String(c.Old, format.Options{})
+	}
+
+	return "~ " + path + ": " + format.String(c.Old, format.Options{}) + " -> " + format.String(c.New, format.Options{})
+}
+
+// Diff returns the changes from a to b. The comparison is semantic: the
+// order of the members and the spelling of the numbers do not matter, and
+// a repeated key is the last of its members. The elements of arrays are
+// compared by index, the extra ones are added or removed at the end, the
+// last first, so the changes can be applied in order.
+func Diff(a, b ast.Node) []Change {
+	var changes []Change
+	diff(a, b, "", &changes)
+
+	return changes
+}
+
+func diff(a, b ast.Node, path string, changes *[]Change) {
+	switch a := a.(type) {
+	case *ast.Object:
+		if b, ok := b.(*ast.Object); ok {
+			diffObjects(a, b, path, changes)
+			return
+		}
+
+	case *ast.Array:
+		if b, ok := b.(*ast.Array); ok {
+			diffArrays(a, b, path, changes)
+			return
+		}
+	}
+
+	if !ast.Equal(a, b) {
+		*changes = append(*changes, Change{Path: path, Old: a, New: b})
+	}
+}
+
+func diffObjects(a, b *ast.Object, path string, changes *[]Change) {
+	seen := make(map[string]bool, a.Len())
+
+	for _, m := range a.Members {
+		if seen[m.Key.Value] {
+			continue
+		}
+		seen[m.Key.Value] = true
+
+		old, _ := a.Get(m.Key.Value)
+		if value, found := b.Get(m.Key.Value); found {
+			diff(old, value, path+"/"+escape(m.Key.Value), changes)
+		} else {
+			*changes = append(*changes, Change{Path: path + "/" + escape(m.Key.Value), Old: old})
+		}
+	}
+
+	for _, m := range b.Members {
+		if seen[m.Key.Value] {
+			continue
+		}
+		seen[m.Key.Value] = true
+
+		value, _ := b.Get(m.Key.Value)
+		*changes = append(*changes, Change{Path: path + "/" + escape(m.Key.Value), New: value})
+	}
+}
+
+func diffArrays(a, b *ast.Array, path string, changes *[]Change) {
+	n := min(a.Len(), b.Len())
+
+	for i := 0; i < n; i++ {
+		diff(a.Elements[i], b.Elements[i], path+"/"+strconv.Itoa(i), changes)
+	}
+	for i := n; i < b.Len(); i++ {
+		*changes = append(*changes, Change{Path: path + "/" + strconv.Itoa(i), New: b.Elements[i]})
+	}
+	for i := a.Len() - 1; i >= n; i-- {
+		*changes = append(*changes, Change{Path: path + "/" + strconv.Itoa(i), Old: a.Elements[i]})
+	}
+}
+
+// FromDiff returns the JSON Patch made of the changes of a diff
+func FromDiff(changes []Change) Patch {
+	patch := make(Patch, len(changes))
+
+	for i, c := range changes {
+		switch {
+		case c.Old == nil:
+			patch[i] = Operation{Op: "add", Path: c.Path, Value: c.New}
+		case c.New == nil:
+			patch[i] = Operation{Op: "remove", Path: c.Path}
+		default:
+			patch[i] = Operation{Op: "replace", Path: c.Path, Value: c.New}
+		}
+	}
+
+	return patch
+}
@@ -0,0 +1,463 @@
+// Package patch compares JSON documents and changes them with JSON Patch,
+// RFC 6902, and JSON Merge Patch, RFC 7386
+package patch
+
+import (
+	"fmt"
+	"jp/ast"
+	"strconv"
+	"strings"
+)
+
+// Operation is an operation of a JSON Patch: add, remove, replace, move,
+// copy or test. Path and From are JSON Pointers, like /items/0/name.
+type Operation struct {
+	Op    string
+	Path  string
+	From  string   // the source of move and copy
+	Value ast.Node // the value of add, replace and test
+}
+
+// Patch is a JSON Patch, its operations are applied in order
+type Patch []Operation
+
+// Error is a patch that can not be applied, or that is malformed
+type Error struct {
+	Index int // index of the operation in the patch, -1 for the whole patch
+	Op    string
+	Path  string
+	Msg   string
+}
+
+func (e *Error) Error() string {
+	if e.Index < 0 {
+		return e.Msg
+	}
+	if e.Op == "" {
+		return fmt.Sprintf("operation %d: %s", e.Index, e.Msg)
+	}
+
+	return fmt.Sprintf("operation %d (%s %q): %s", e.Index, e.Op, e.Path, e.Msg)
+}
+
+// Parse returns the JSON Patch in node, an array of operations
+func Parse(node ast.Node) (Patch, error) {
+	arr, ok := node.(*ast.Array)
+	if !ok {
+		return nil, &Error{Index: -1, Msg: "a JSON Patch must be an array of operations"}
+	}
+
+	patch := make(Patch, arr.Len())
+	for i, elem := range arr.Elements {
+		obj, ok := elem.(*ast.Object)
+		if !ok {
+			return nil, &Error{Index: i, Msg: "an operation must be an object"}
+		}
+
+		op := &patch[i]
+
+		var err error
+		if op.Op, err = stringMember(obj, "op", i); err != nil {
+			return nil, err
+		}
+		if op.Path, err = stringMember(obj, "path", i); err != nil {
+			return nil, err
+		}
+
+		switch op.Op {
+		case "add", "replace", "test":
+			value, found := obj.Get("value")
+			if !found {
+				return nil, &Error{Index: i, Op: op.Op, Path: op.Path, Msg: `missing "value"`}
+			}
+			op.Value = value
+
+		case "move", "copy":
+			if op.From, err = stringMember(obj, "from", i); err != nil {
+				return nil, err
+			}
+
+		case "remove":
+
+		default:
+			return nil, &Error{Index: i, Op: op.Op, Path: op.Path, Msg: "unknown operation"}
+		}
+	}
+
+	return patch, nil
+}
+
+func stringMember(obj *ast.Object, key string, i int) (string, error) {
+	value, found := obj.Get(key)
+	if !found {
+		return "", &Error{Index: i, Msg: fmt.Sprintf("missing %q", key)}
+	}
+
+	s, ok := value.(*ast.String)
+	if !ok {
+		return "", &Error{Index: i, Msg: fmt.Sprintf("%q must be a string", key)}
+	}
+
+	return s.Value, nil
+}
+
+// Node returns the patch as JSON
+func (p Patch) Node() ast.Node {
+	arr := &ast.Array{}
+
+	for _, op := range p {
+		obj := &ast.Object{}
+		set(obj, "op", &ast.String{Value: op.Op})
+		if op.From != "" || op.Op == "move" || op.Op == "copy" {
+			set(obj, "from", &ast.String{Value: op.From})
+		}
+		set(obj, "path", &ast.String{Value: op.Path})
+		if op.Value != nil {
+			set(obj, "value", op.Value)
+		}
+
+		arr.Elements = append(arr.Elements, obj)
+	}
+
+	return arr
+}
+
+// Apply applies the patch to doc and returns the patched document. doc is
+// left unchanged, and when an operation fails no change is kept, the patch
+// is applied as a whole or not at all.
+func Apply(doc ast.Node, patch Patch) (ast.Node, error) {
+	doc = Clone(doc)
+
+	for i, op := range patch {
+		var err error
+
+		switch op.Op {
+		case "add":
+			doc, err = add(doc, op.Path, Clone(op.Value))
+
+		case "remove":
+			doc, _, err = remove(doc, op.Path)
+
+		case "replace":
+			doc, err = replace(doc, op.Path, Clone(op.Value))
+
+		case "move":
+			if op.Path == op.From {
+				_, err = get(doc, op.From)
+				break
+			}
+			if strings.HasPrefix(op.Path, op.From+"/") {
+				err = fmt.Errorf("can not move %q into one of its children", op.From)
+				break
+			}
+
+			var value ast.Node
+			if doc, value, err = remove(doc, op.From); err == nil {
+				doc, err = add(doc, op.Path, value)
+			}
+
+		case "copy":
+			var value ast.Node
+			if value, err = get(doc, op.From); err == nil {
+				doc, err = add(doc, op.Path, Clone(value))
+			}
+
+		case "test":
+			var value ast.Node
+			if value, err = get(doc, op.Path); err == nil && !ast.Equal(value, op.Value) {
+				err = fmt.Errorf("test failed")
+			}
+
+		default:
+			err = fmt.Errorf("unknown operation")
+		}
+
+		if err != nil {
+			return nil, &Error{Index: i, Op: op.Op, Path: op.Path, Msg: err.Error()}
+		}
+	}
+
+	return doc, nil
+}
+
+// MergePatch applies the JSON Merge Patch patch to doc and returns the
+// patched document, doc is left unchanged. The members of patch replace
+// the members of doc, null ones remove them, and patch replaces doc when
+// one of them is not an object.
+func MergePatch(doc, patch ast.Node) ast.Node {
+	p, ok := patch.(*ast.Object)
+	if !ok {
+		return Clone(patch)
+	}
+
+	obj, ok := Clone(doc).(*ast.Object)
+	if !ok {
+		obj = &ast.Object{Position: p.Position}
+	}
+
+	for _, m := range p.Members {
+		if _, isNull := m.Value.(*ast.Null); isNull {
+			del(obj, m.Key.Value)
+			continue
+		}
+
+		current, _ := obj.Get(m.Key.Value)
+		set(obj, m.Key.Value, MergePatch(current, m.Value))
+	}
+
+	return obj
+}
+
+// Clone returns a deep copy of node
+func Clone(node ast.Node) ast.Node {
+	switch node := node.(type) {
+	case *ast.Object:
+		obj := &ast.Object{Position: node.Position, Members: make([]*ast.Member, len(node.Members))}
+		for i, m := range node.Members {
+			key := *m.Key
+			obj.Members[i] = &ast.Member{Key: &key, Value: Clone(m.Value)}
+		}
+		return obj
+
+	case *ast.Array:
+		arr := &ast.Array{Position: node.Position, Elements: make([]ast.Node, len(node.Elements))}
+		for i, elem := range node.Elements {
+			arr.Elements[i] = Clone(elem)
+		}
+		return arr
+
+	case *ast.String:
+		s := *node
+		return &s
+	case *ast.Number:
+		n := *node
+		return &n
+	case *ast.Bool:
+		b := *node
+		return &b
+	case *ast.Null:
+		n := *node
+		return &n
+	}
+
+	return nil
+}
+
+// get returns the value at the JSON Pointer path
+func get(doc ast.Node, path string) (ast.Node, error) {
+	tokens, err := splitPointer(path)
+	if err != nil {
+		return nil, err
+	}
+
+	node := doc
+	for i, token := range tokens {
+		if node, err = child(node, token); err != nil {
+			return nil, fmt.Errorf("%q: %w", joinPointer(tokens[:i+1]), err)
+		}
+	}
+
+	return node, nil
+}
+
+// child returns the member or element of node named token
+func child(node ast.Node, token string) (ast.Node, error) {
+	switch node := node.(type) {
+	case *ast.Object:
+		value, found := node.Get(token)
+		if !found {
+			return nil, fmt.Errorf("no such member")
+		}
+		return value, nil
+
+	case *ast.Array:
+		i, err := index(token, node.Len())
+		if err != nil {
+			return nil, err
+		}
+		if i == node.Len() {
+			return nil, fmt.Errorf("index out of range")
+		}
+		return node.Elements[i], nil
+	}
+
+	return nil, fmt.Errorf("not an object or an array")
+}
+
+// add adds value at path, the member of an object is replaced and the
+// element of an array inserted
+func add(doc ast.Node, path string, value ast.Node) (ast.Node, error) {
+	if path == "" {
+		return value, nil
+	}
+
+	parent, last, err := parentOf(doc, path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch parent := parent.(type) {
+	case *ast.Object:
+		set(parent, last, value)
+
+	case *ast.Array:
+		i := parent.Len()
+		if last != "-" {
+			if i, err = index(last, parent.Len()); err != nil {
+				return nil, err
+			}
+		}
+		parent.Elements = append(parent.Elements, nil)
+		copy(parent.Elements[i+1:], parent.Elements[i:])
+		parent.Elements[i] = value
+
+	default:
+		return nil, fmt.Errorf("parent is not an object or an array")
+	}
+
+	return doc, nil
+}
+
+// replace replaces the value at path, in place
+func replace(doc ast.Node, path string, value ast.Node) (ast.Node, error) {
+	if _, err := get(doc, path); err != nil {
+		return nil, err
+	}
+	if path == "" {
+		return value, nil
+	}
+
+	parent, last, err := parentOf(doc, path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch parent := parent.(type) {
+	case *ast.Object:
+		set(parent, last, value)
+	case *ast.Array:
+		i, _ := index(last, parent.Len())
+		parent.Elements[i] = value
+	}
+
+	return doc, nil
+}
+
+// remove removes the value at path and returns it
+func remove(doc ast.Node, path string) (ast.Node, ast.Node, error) {
+	if path == "" {
+		return nil, nil, fmt.Errorf("can not remove the whole document")
+	}
+
+	value, err := get(doc, path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	parent, last, err := parentOf(doc, path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch parent := parent.(type) {
+	case *ast.Object:
+		del(parent, last)
+	case *ast.Array:
+		i, _ := index(last, parent.Len())
+		parent.Elements = append(parent.Elements[:i], parent.Elements[i+1:]...)
+	}
+
+	return doc, value, nil
+}
+
+// parentOf returns the parent of the value at path, and the last token of
+// path
+func parentOf(doc ast.Node, path string) (ast.Node, string, error) {
+	tokens, err := splitPointer(path)
+	if err != nil {
+		return nil, "", err
+	}
+
+	parent, err := get(doc, joinPointer(tokens[:len(tokens)-1]))
+	if err != nil {
+		return nil, "", err
+	}
+
+	return parent, tokens[len(tokens)-1], nil
+}
+
+// index returns the array index token, at most n
+func index(token string, n int) (int, error) {
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || token[0] == '+' || len(token) > 1 && token[0] == '0' {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	if i > n {
+		return 0, fmt.Errorf("index out of range")
+	}
+
+	return i, nil
+}
+
+// set sets the member key of obj, replacing every member of that name
+func set(obj *ast.Object, key string, value ast.Node) {
+	for i, m := range obj.Members {
+		if m.Key.Value == key {
+			obj.Members[i] = &ast.Member{Key: m.Key, Value: value}
+			rest := keep(obj.Members[i+1:], key)
+			obj.Members = obj.Members[:i+1+len(rest)]
+			return
+		}
+	}
+
+	obj.Members = append(obj.Members, &ast.Member{Key: &ast.String{Value: key}, Value: value})
+}
+
+// del removes the members of obj named key
+func del(obj *ast.Object, key string) {
+	obj.Members = keep(obj.Members, key)
+}
+
+// keep returns the members not named key, reusing the array of members
+func keep(members []*ast.Member, key string) []*ast.Member {
+	kept := members[:0]
+	for _, m := range members {
+		if m.Key.Value != key {
+			kept = append(kept, m)
+		}
+	}
+
+	return kept
+}
+
+// splitPointer returns the unescaped tokens of a JSON Pointer
+func splitPointer(path string) ([]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+	if path[0] != '/' {
+		return nil, fmt.Errorf("invalid JSON Pointer %q, it must start with /", path)
+	}
+
+	tokens := strings.Split(path[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+	}
+
+	return tokens, nil
+}
+
+// joinPointer returns the JSON Pointer of tokens
+func joinPointer(tokens []string) string {
+	var b strings.Builder
+	for _, token := range tokens {
+		b.WriteString("/" + escape(token))
+	}
+
+	return b.String()
+}
+
+// escape escapes a key as a JSON Pointer token
+func escape(key string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+}
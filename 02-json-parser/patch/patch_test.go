@@ -0,0 +1,371 @@
+package patch
+
+import (
+	"jp/ast"
+	"jp/format"
+	"jp/parser"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func mustParse(t *testing.T, src string) ast.Node {
+	t.Helper()
+
+	node, err := parser.Parse(src)
+	if err != nil {
+		t.Fatalf("Parse(%q) error = %v", src, err)
+	}
+
+	return node
+}
+
+// the examples of RFC 6902, appendix A, and a few more
+func Test_Apply(t *testing.T) {
+	tests := []struct {
+		name    string
+		doc     string
+		patch   string
+		want    string
+		wantErr string
+	}{
+		{
+			name:  "add an object member",
+			doc:   `{"foo": "bar"}`,
+			patch: `[{"op": "add", "path": "/baz", "value": "qux"}]`,
+			want:  `{"foo":"bar","baz":"qux"}`,
+		},
+		{
+			name:  "add an array element",
+			doc:   `{"foo": ["bar", "baz"]}`,
+			patch: `[{"op": "add", "path": "/foo/1", "value": "qux"}]`,
+			want:  `{"foo":["bar","qux","baz"]}`,
+		},
+		{
+			name:  "remove an object member",
+			doc:   `{"baz": "qux", "foo": "bar"}`,
+			patch: `[{"op": "remove", "path": "/baz"}]`,
+			want:  `{"foo":"bar"}`,
+		},
+		{
+			name:  "remove an array element",
+			doc:   `{"foo": ["bar", "qux", "baz"]}`,
+			patch: `[{"op": "remove", "path": "/foo/1"}]`,
+			want:  `{"foo":["bar","baz"]}`,
+		},
+		{
+			name:  "replace a value",
+			doc:   `{"baz": "qux", "foo": "bar"}`,
+			patch: `[{"op": "replace", "path": "/baz", "value": "boo"}]`,
+			want:  `{"baz":"boo","foo":"bar"}`,
+		},
+		{
+			name:  "move a value",
+			doc:   `{"foo": {"bar": "baz", "waldo": "fred"}, "qux": {"corge": "grault"}}`,
+			patch: `[{"op": "move", "from": "/foo/waldo", "path": "/qux/thud"}]`,
+			want:  `{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`,
+		},
+		{
+			name:  "move an array element",
+			doc:   `{"foo": ["all", "grass", "cows", "eat"]}`,
+			patch: `[{"op": "move", "from": "/foo/1", "path": "/foo/3"}]`,
+			want:  `{"foo":["all","cows","eat","grass"]}`,
+		},
+		{
+			name:  "test a value",
+			doc:   `{"baz": "qux", "foo": ["a", 2, "c"]}`,
+			patch: `[{"op": "test", "path": "/baz", "value": "qux"}, {"op": "test", "path": "/foo/1", "value": 2.0}]`,
+			want:  `{"baz":"qux","foo":["a",2,"c"]}`,
+		},
+		{
+			name:    "failed test",
+			doc:     `{"baz": "qux"}`,
+			patch:   `[{"op": "test", "path": "/baz", "value": "bar"}]`,
+			wantErr: `operation 0 (test "/baz"): test failed`,
+		},
+		{
+			name:  "add a nested member",
+			doc:   `{"foo": "bar"}`,
+			patch: `[{"op": "add", "path": "/child", "value": {"grandchild": {}}}]`,
+			want:  `{"foo":"bar","child":{"grandchild":{}}}`,
+		},
+		{
+			name:  "ignore unknown members",
+			doc:   `{"foo": "bar"}`,
+			patch: `[{"op": "add", "path": "/baz", "value": "qux", "xyz": 123}]`,
+			want:  `{"foo":"bar","baz":"qux"}`,
+		},
+		{
+			name:    "add to a nonexistent target",
+			doc:     `{"foo": "bar"}`,
+			patch:   `[{"op": "add", "path": "/baz/bat", "value": "qux"}]`,
+			wantErr: `operation 0 (add "/baz/bat"): "/baz": no such member`,
+		},
+		{
+			name:  "escaped pointer",
+			doc:   `{"/": 9, "~1": 10}`,
+			patch: `[{"op": "test", "path": "/~01", "value": 10}, {"op": "remove", "path": "/~1"}]`,
+			want:  `{"~1":10}`,
+		},
+		{
+			name:    "string is not a number",
+			doc:     `{"/": 9, "~1": 10}`,
+			patch:   `[{"op": "test", "path": "/~01", "value": "10"}]`,
+			wantErr: `operation 0 (test "/~01"): test failed`,
+		},
+		{
+			name:  "add an array value",
+			doc:   `{"foo": ["bar"]}`,
+			patch: `[{"op": "add", "path": "/foo/-", "value": ["abc", "def"]}]`,
+			want:  `{"foo":["bar",["abc","def"]]}`,
+		},
+		{
+			name:  "copy a value",
+			doc:   `{"a": {"b": [1]}}`,
+			patch: `[{"op": "copy", "from": "/a", "path": "/c"}, {"op": "add", "path": "/c/b/-", "value": 2}]`,
+			want:  `{"a":{"b":[1]},"c":{"b":[1,2]}}`,
+		},
+		{
+			name:  "replace the document",
+			doc:   `{"a": 1}`,
+			patch: `[{"op": "replace", "path": "", "value": [1]}]`,
+			want:  `[1]`,
+		},
+		{
+			name:  "replace a repeated key",
+			doc:   `{"a": 1, "b": 2, "a": 3}`,
+			patch: `[{"op": "replace", "path": "/a", "value": 4}]`,
+			want:  `{"a":4,"b":2}`,
+		},
+		{
+			name:    "index out of range",
+			doc:     `[1, 2]`,
+			patch:   `[{"op": "add", "path": "/3", "value": 3}]`,
+			wantErr: `operation 0 (add "/3"): index out of range`,
+		},
+		{
+			name:    "leading zero index",
+			doc:     `[1, 2]`,
+			patch:   `[{"op": "remove", "path": "/01"}]`,
+			wantErr: `operation 0 (remove "/01"): "/01": invalid array index "01"`,
+		},
+		{
+			name:    "move into a child",
+			doc:     `{"a": {"b": 1}}`,
+			patch:   `[{"op": "move", "from": "/a", "path": "/a/c"}]`,
+			wantErr: `operation 0 (move "/a/c"): can not move "/a" into one of its children`,
+		},
+		{
+			name:    "atomic",
+			doc:     `{"a": 1}`,
+			patch:   `[{"op": "remove", "path": "/a"}, {"op": "remove", "path": "/a"}]`,
+			wantErr: `operation 1 (remove "/a"): "/a": no such member`,
+		},
+		{
+			name:    "missing value",
+			doc:     `{}`,
+			patch:   `[{"op": "add", "path": "/a"}]`,
+			wantErr: `operation 0 (add "/a"): missing "value"`,
+		},
+		{
+			name:    "unknown operation",
+			doc:     `{}`,
+			patch:   `[{"op": "merge", "path": "/a"}]`,
+			wantErr: `operation 0 (merge "/a"): unknown operation`,
+		},
+		{
+			name:    "missing path",
+			doc:     `{}`,
+			patch:   `[{"op": "remove"}]`,
+			wantErr: `operation 0: missing "path"`,
+		},
+		{
+			name:    "not an array",
+			doc:     `{}`,
+			patch:   `{"op": "remove", "path": "/a"}`,
+			wantErr: `a JSON Patch must be an array of operations`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := mustParse(t, tt.doc)
+			before := format.String(doc, format.Options{})
+
+			patch, err := Parse(mustParse(t, tt.patch))
+			var got ast.Node
+			if err == nil {
+				got, err = Apply(doc, patch)
+			}
+
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("error = %v, want %s", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("error = %v", err)
+			} else if s := format.String(got, format.Options{}); s != tt.want {
+				t.Errorf("Apply() = %s, want %s", s, tt.want)
+			}
+
+			if after := format.String(doc, format.Options{}); after != before {
+				t.Errorf("Apply() changed the document to %s", after)
+			}
+		})
+	}
+}
+
+// the examples of RFC 7386, appendix A
+func Test_MergePatch(t *testing.T) {
+	tests := []struct {
+		doc   string
+		patch string
+		want  string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.doc+" "+tt.patch, func(t *testing.T) {
+			doc := mustParse(t, tt.doc)
+
+			got := format.String(MergePatch(doc, mustParse(t, tt.patch)), format.Options{})
+			if got != tt.want {
+				t.Errorf("MergePatch() = %s, want %s", got, tt.want)
+			}
+			if s := format.String(doc, format.Options{}); s != tt.doc {
+				t.Errorf("MergePatch() changed the document to %s", s)
+			}
+		})
+	}
+}
+
+func Test_Diff(t *testing.T) {
+	tests := []struct {
+		name string
+		a    string
+		b    string
+		want []string
+	}{
+		{
+			name: "same document",
+			a:    `{"a": [1, 2, {"b": null}], "c": "d"}`,
+			b:    "{\n  \"c\": \"d\",\n  \"a\": [1.0, 2e0, {\"b\": null}]\n}",
+		},
+		{
+			name: "members",
+			a:    `{"a": 1, "b": true, "c": {"d": "e"}}`,
+			b:    `{"c": {"d": "f", "g": []}, "a": 2, "h": null}`,
+			want: []string{
+				`~ /a: 1 -> 2`,
+				`- /b: true`,
+				`~ /c/d: "e" -> "f"`,
+				`+ /c/g: []`,
+				`+ /h: null`,
+			},
+		},
+		{
+			name: "longer array",
+			a:    `[1, 2]`,
+			b:    `[1, 3, 4, 5]`,
+			want: []string{`~ /1: 2 -> 3`, `+ /2: 4`, `+ /3: 5`},
+		},
+		{
+			name: "shorter array",
+			a:    `[1, 2, 3, 4]`,
+			b:    `[0, 2]`,
+			want: []string{`~ /0: 1 -> 0`, `- /3: 4`, `- /2: 3`},
+		},
+		{
+			name: "type change",
+			a:    `{"a/b": {"c": 1}}`,
+			b:    `{"a/b": [1]}`,
+			want: []string{`~ /a~1b: {"c":1} -> [1]`},
+		},
+		{
+			name: "root",
+			a:    `{}`,
+			b:    `[]`,
+			want: []string{`~ (root): {} -> []`},
+		},
+		{
+			name: "repeated key",
+			a:    `{"a": 1, "a": 2}`,
+			b:    `{"a": 2}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := mustParse(t, tt.a), mustParse(t, tt.b)
+
+			changes := Diff(a, b)
+			var got []string
+			for _, c := range changes {
+				got = append(got, c.String())
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("Diff() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+
+			patched, err := Apply(a, FromDiff(changes))
+			if err != nil {
+				t.Fatalf("Apply() error = %v", err)
+			}
+			if !ast.Equal(patched, b) {
+				t.Errorf("Apply(a, Diff(a, b)) = %s, want b", format.String(patched, format.Options{}))
+			}
+		})
+	}
+}
+
+// the patch of a diff turns any document of the tests into any other
+func Test_DiffFiles(t *testing.T) {
+	files, _ := filepath.Glob("../test/pass*.json")
+	jsonc, _ := filepath.Glob("../test/jsonc/pass*.json")
+	files = append(files, jsonc...)
+
+	var docs []ast.Node
+	for _, file := range files {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		docs = append(docs, mustParse(t, string(src)))
+	}
+
+	for i, a := range docs {
+		for j, b := range docs {
+			patch := FromDiff(Diff(a, b))
+			if i == j && len(patch) > 0 {
+				t.Errorf("Diff(%s, %s) = %d changes, want none", files[i], files[j], len(patch))
+			}
+
+			// through JSON, like the patch command
+			patch, err := Parse(mustParse(t, format.String(patch.Node(), format.Options{})))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := Apply(a, patch)
+			if err != nil {
+				t.Fatalf("Apply(%s, Diff(%s, %s)) error = %v", files[i], files[i], files[j], err)
+			}
+			if !ast.Equal(got, b) {
+				t.Errorf("Apply(%s, Diff(%s, %s)) is not %s", files[i], files[i], files[j], files[j])
+			}
+		}
+	}
+}
@@ -0,0 +1,598 @@
+// Package schema validates JSON documents against a JSON Schema, a subset
+// of draft 2020-12: type, enum, const, the numeric, string and array
+// bounds, pattern, required, properties, additionalProperties, items,
+// allOf, anyOf, oneOf and $ref to the schema itself, like "#/$defs/name".
+// The other keywords are ignored.
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"jp/ast"
+	"jp/format"
+	"jp/token"
+	"math"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Schema is a compiled schema, ready to validate documents
+type Schema struct {
+	root *schema
+}
+
+// SchemaError is a malformed schema
+type SchemaError struct {
+	Path string         // JSON Pointer of the malformed keyword, like #/properties/age/minimum
+	Pos  token.Position // position of the keyword value in the schema
+	Msg  string
+}
+
+func (e *SchemaError) Error() string {
+	return fmt.Sprintf("%s: %s: %s", e.Pos, e.Path, e.Msg)
+}
+
+// Violation is a part of a document that does not match the schema
+type Violation struct {
+	InstancePath string         // JSON Pointer of the value in the document, "" for the root
+	SchemaPath   string         // JSON Pointer of the keyword in the schema, like #/properties/age/minimum
+	Pos          token.Position // position of the value in the document
+	Msg          string
+}
+
+func (v Violation) Error() string {
+	path := v.InstancePath
+	if path == "" {
+		path = "(root)"
+	}
+
+	return fmt.Sprintf("%s: %s: %s (%s)", v.Pos, path, v.Msg, v.SchemaPath)
+}
+
+// schema is a compiled schema object, or a boolean schema
+type schema struct {
+	path string // JSON Pointer of the schema
+
+	always *bool // the boolean schema true or false
+
+	types []string
+	enum  []ast.Node
+	konst ast.Node
+
+	minimum, maximum                   *number
+	exclusiveMinimum, exclusiveMaximum *number
+
+	minLength, maxLength *int
+	pattern              *regexp.Regexp
+	minItems, maxItems   *int
+	items                *schema
+
+	required             []string
+	properties           []property
+	additionalProperties *schema
+
+	allOf, anyOf, oneOf []*schema
+
+	ref    string         // the $ref
+	refPos token.Position // position of the $ref in the schema
+	target *schema        // the schema ref leads to
+}
+
+type property struct {
+	name   string
+	schema *schema
+}
+
+// number is a numeric keyword, the literal is kept for the messages
+type number struct {
+	value   float64
+	literal string
+}
+
+var typeNames = map[string]bool{
+	"null": true, "boolean": true, "object": true, "array": true,
+	"number": true, "string": true, "integer": true,
+}
+
+// Compile compiles the schema in node. A malformed schema returns a
+// *SchemaError.
+func Compile(node ast.Node) (*Schema, error) {
+	c := compiler{doc: node, schemas: map[string]*schema{}}
+
+	root, err := c.compile(node, "#")
+	if err != nil {
+		return nil, err
+	}
+
+	// every reference must lead to a schema
+	for len(c.refs) > 0 {
+		ref := c.refs[0]
+		c.refs = c.refs[1:]
+
+		target, err := c.resolve(ref)
+		if err != nil {
+			return nil, err
+		}
+		ref.target = target
+	}
+
+	return &Schema{root: root}, nil
+}
+
+// compiler compiles a schema document, the schemas are kept by path so a
+// $ref leads to the schema compiled once
+type compiler struct {
+	doc     ast.Node
+	schemas map[string]*schema
+	refs    []*schema // schemas with a $ref, to resolve
+}
+
+func (c *compiler) compile(node ast.Node, path string) (*schema, error) {
+	if s, ok := c.schemas[path]; ok {
+		return s, nil
+	}
+
+	s := &schema{path: path}
+	c.schemas[path] = s
+
+	switch node := node.(type) {
+	case *ast.Bool:
+		s.always = &node.Value
+		return s, nil
+
+	case *ast.Object:
+		for _, m := range node.Members {
+			if err := c.keyword(s, m.Key.Value, m.Value, path+"/"+escape(m.Key.Value)); err != nil {
+				return nil, err
+			}
+		}
+		return s, nil
+	}
+
+	return nil, &SchemaError{Path: path, Pos: node.Pos(), Msg: "schema must be an object or a boolean"}
+}
+
+// keyword compiles the keyword named key in s
+func (c *compiler) keyword(s *schema, key string, value ast.Node, path string) error {
+	var err error
+
+	switch key {
+	case "type":
+		s.types, err = stringList(value, path)
+		if err == nil {
+			for _, t := range s.types {
+				if !typeNames[t] {
+					return &SchemaError{Path: path, Pos: value.Pos(), Msg: fmt.Sprintf("unknown type %q", t)}
+				}
+			}
+		}
+
+	case "enum":
+		arr, ok := value.(*ast.Array)
+		if !ok {
+			return &SchemaError{Path: path, Pos: value.Pos(), Msg: "enum must be an array"}
+		}
+		s.enum = arr.Elements
+
+	case "const":
+		s.konst = value
+
+	case "minimum":
+		s.minimum, err = numberKeyword(value, path)
+	case "maximum":
+		s.maximum, err = numberKeyword(value, path)
+	case "exclusiveMinimum":
+		s.exclusiveMinimum, err = numberKeyword(value, path)
+	case "exclusiveMaximum":
+		s.exclusiveMaximum, err = numberKeyword(value, path)
+
+	case "minLength":
+		s.minLength, err = countKeyword(value, path)
+	case "maxLength":
+		s.maxLength, err = countKeyword(value, path)
+	case "minItems":
+		s.minItems, err = countKeyword(value, path)
+	case "maxItems":
+		s.maxItems, err = countKeyword(value, path)
+
+	case "pattern":
+		str, ok := value.(*ast.String)
+		if !ok {
+			return &SchemaError{Path: path, Pos: value.Pos(), Msg: "pattern must be a string"}
+		}
+		s.pattern, err = regexp.Compile(str.Value)
+		if err != nil {
+			return &SchemaError{Path: path, Pos: value.Pos(), Msg: fmt.Sprintf("invalid pattern: %v", err)}
+		}
+
+	case "items":
+		s.items, err = c.compile(value, path)
+
+	case "required":
+		s.required, err = stringList(value, path)
+
+	case "properties":
+		obj, ok := value.(*ast.Object)
+		if !ok {
+			return &SchemaError{Path: path, Pos: value.Pos(), Msg: "properties must be an object"}
+		}
+		for _, m := range obj.Members {
+			prop, err := c.compile(m.Value, path+"/"+escape(m.Key.Value))
+			if err != nil {
+				return err
+			}
+			s.properties = append(s.properties, property{name: m.Key.Value, schema: prop})
+		}
+
+	case "additionalProperties":
+		s.additionalProperties, err = c.compile(value, path)
+
+	case "allOf", "anyOf", "oneOf":
+		arr, ok := value.(*ast.Array)
+		if !ok || arr.Len() == 0 {
+			return &SchemaError{Path: path, Pos: value.Pos(), Msg: key + " must be a non empty array"}
+		}
+		list := make([]*schema, arr.Len())
+		for i, elem := range arr.Elements {
+			if list[i], err = c.compile(elem, path+"/"+strconv.Itoa(i)); err != nil {
+				return err
+			}
+		}
+		switch key {
+		case "allOf":
+			s.allOf = list
+		case "anyOf":
+			s.anyOf = list
+		default:
+			s.oneOf = list
+		}
+
+	case "$ref":
+		str, ok := value.(*ast.String)
+		if !ok {
+			return &SchemaError{Path: path, Pos: value.Pos(), Msg: "$ref must be a string"}
+		}
+		if !strings.HasPrefix(str.Value, "#") {
+			return &SchemaError{Path: path, Pos: value.Pos(), Msg: fmt.Sprintf("$ref %q is not local to the schema", str.Value)}
+		}
+		s.ref = str.Value
+		s.refPos = value.Pos()
+		c.refs = append(c.refs, s)
+
+	case "$defs", "definitions":
+		// compiled when referenced
+		if _, ok := value.(*ast.Object); !ok {
+			return &SchemaError{Path: path, Pos: value.Pos(), Msg: key + " must be an object"}
+		}
+	}
+
+	return err
+}
+
+// resolve compiles the schema the $ref of s leads to
+func (c *compiler) resolve(s *schema) (*schema, error) {
+	pointer, err := url.PathUnescape(s.ref[1:])
+	if err != nil {
+		return nil, &SchemaError{Path: s.path + "/$ref", Pos: s.refPos, Msg: fmt.Sprintf("invalid $ref %q", s.ref)}
+	}
+
+	node := c.doc
+	path := "#"
+
+	if pointer != "" {
+		if !strings.HasPrefix(pointer, "/") {
+			return nil, &SchemaError{Path: s.path + "/$ref", Pos: s.refPos, Msg: fmt.Sprintf("$ref %q must be a JSON Pointer, like #/$defs/name", s.ref)}
+		}
+
+		for _, token := range strings.Split(pointer[1:], "/") {
+			key := strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+
+			var found bool
+			switch n := node.(type) {
+			case *ast.Object:
+				node, found = n.Get(key)
+			case *ast.Array:
+				if i, err := strconv.Atoi(key); err == nil {
+					node, found = n.Index(i)
+				}
+			}
+			if !found {
+				return nil, &SchemaError{Path: s.path + "/$ref", Pos: s.refPos, Msg: fmt.Sprintf("$ref %q leads nowhere", s.ref)}
+			}
+
+			path += "/" + escape(key)
+		}
+	}
+
+	return c.compile(node, path)
+}
+
+// stringList returns a string, or an array of strings, as a list
+func stringList(node ast.Node, path string) ([]string, error) {
+	if s, ok := node.(*ast.String); ok {
+		return []string{s.Value}, nil
+	}
+
+	arr, ok := node.(*ast.Array)
+	if !ok {
+		return nil, &SchemaError{Path: path, Pos: node.Pos(), Msg: "must be a string or an array of strings"}
+	}
+
+	list := make([]string, arr.Len())
+	for i, elem := range arr.Elements {
+		s, ok := elem.(*ast.String)
+		if !ok {
+			return nil, &SchemaError{Path: path, Pos: elem.Pos(), Msg: "must be a string or an array of strings"}
+		}
+		list[i] = s.Value
+	}
+
+	return list, nil
+}
+
+func numberKeyword(node ast.Node, path string) (*number, error) {
+	n, ok := node.(*ast.Number)
+	if !ok {
+		return nil, &SchemaError{Path: path, Pos: node.Pos(), Msg: "must be a number"}
+	}
+
+	f, _ := n.Float64()
+
+	return &number{value: f, literal: n.Literal}, nil
+}
+
+// countKeyword returns the non negative integer of a length or count
+// keyword
+func countKeyword(node ast.Node, path string) (*int, error) {
+	n, ok := node.(*ast.Number)
+	if ok {
+		i, err := n.Int64()
+		if err == nil && i >= 0 && i <= math.MaxInt32 {
+			count := int(i)
+			return &count, nil
+		}
+	}
+
+	return nil, &SchemaError{Path: path, Pos: node.Pos(), Msg: "must be a non negative integer"}
+}
+
+// Validate returns the violations of the schema by the document node, none
+// when it is valid
+func (s *Schema) Validate(node ast.Node) []Violation {
+	v := validator{active: map[check]bool{}}
+	v.validate(s.root, node, "")
+
+	return v.violations
+}
+
+type validator struct {
+	violations []Violation
+
+	// the schemas being validated against the values, a schema reached again
+	// for the same value through $ref, like {"$ref": "#"}, is skipped
+	active map[check]bool
+}
+
+type check struct {
+	s    *schema
+	node ast.Node
+}
+
+func (v *validator) report(s *schema, keyword string, node ast.Node, instancePath, msg string, args ...any) {
+	v.violations = append(v.violations, Violation{
+		InstancePath: instancePath,
+		SchemaPath:   s.path + "/" + keyword,
+		Pos:          node.Pos(),
+		Msg:          fmt.Sprintf(msg, args...),
+	})
+}
+
+// matches reports whether node is valid against s, without reporting
+func (v *validator) matches(s *schema, node ast.Node) bool {
+	sub := validator{active: v.active}
+	sub.validate(s, node, "")
+
+	return len(sub.violations) == 0
+}
+
+func (v *validator) validate(s *schema, node ast.Node, instancePath string) {
+	if v.active[check{s, node}] {
+		return
+	}
+	v.active[check{s, node}] = true
+	defer delete(v.active, check{s, node})
+
+	if s.always != nil {
+		if !*s.always {
+			v.violations = append(v.violations, Violation{
+				InstancePath: instancePath,
+				SchemaPath:   s.path,
+				Pos:          node.Pos(),
+				Msg:          "no value is allowed",
+			})
+		}
+		return
+	}
+
+	if s.target != nil {
+		v.validate(s.target, node, instancePath)
+	}
+
+	if len(s.types) > 0 && !hasType(node, s.types) {
+		v.report(s, "type", node, instancePath, "must be of type %s, not %s", strings.Join(s.types, " or "), typeName(node))
+	}
+
+	if s.enum != nil {
+		found := false
+		for _, e := range s.enum {
+			if ast.Equal(node, e) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			values := make([]string, len(s.enum))
+			for i, e := range s.enum {
+				values[i] = format.String(e, format.Options{})
+			}
+			v.report(s, "enum", node, instancePath, "must be one of %s", strings.Join(values, ", "))
+		}
+	}
+
+	if s.konst != nil && !ast.Equal(node, s.konst) {
+		v.report(s, "const", node, instancePath, "must be %s", format.String(s.konst, format.Options{}))
+	}
+
+	switch node := node.(type) {
+	case *ast.Number:
+		v.validateNumber(s, node, instancePath)
+	case *ast.String:
+		v.validateString(s, node, instancePath)
+	case *ast.Array:
+		v.validateArray(s, node, instancePath)
+	case *ast.Object:
+		v.validateObject(s, node, instancePath)
+	}
+
+	for _, sub := range s.allOf {
+		v.validate(sub, node, instancePath)
+	}
+
+	if s.anyOf != nil {
+		found := false
+		for _, sub := range s.anyOf {
+			if v.matches(sub, node) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			v.report(s, "anyOf", node, instancePath, "must match at least one schema of anyOf")
+		}
+	}
+
+	if s.oneOf != nil {
+		n := 0
+		for _, sub := range s.oneOf {
+			if v.matches(sub, node) {
+				n++
+			}
+		}
+		if n != 1 {
+			v.report(s, "oneOf", node, instancePath, "must match exactly one schema of oneOf, matches %d", n)
+		}
+	}
+}
+
+func (v *validator) validateNumber(s *schema, node *ast.Number, instancePath string) {
+	f, _ := node.Float64()
+
+	if s.minimum != nil && f < s.minimum.value {
+		v.report(s, "minimum", node, instancePath, "must be >= %s", s.minimum.literal)
+	}
+	if s.maximum != nil && f > s.maximum.value {
+		v.report(s, "maximum", node, instancePath, "must be <= %s", s.maximum.literal)
+	}
+	if s.exclusiveMinimum != nil && f <= s.exclusiveMinimum.value {
+		v.report(s, "exclusiveMinimum", node, instancePath, "must be > %s", s.exclusiveMinimum.literal)
+	}
+	if s.exclusiveMaximum != nil && f >= s.exclusiveMaximum.value {
+		v.report(s, "exclusiveMaximum", node, instancePath, "must be < %s", s.exclusiveMaximum.literal)
+	}
+}
+
+func (v *validator) validateString(s *schema, node *ast.String, instancePath string) {
+	// the length is in characters
+	n := utf8.RuneCountInString(node.Value)
+
+	if s.minLength != nil && n < *s.minLength {
+		v.report(s, "minLength", node, instancePath, "must be at least %d characters long", *s.minLength)
+	}
+	if s.maxLength != nil && n > *s.maxLength {
+		v.report(s, "maxLength", node, instancePath, "must be at most %d characters long", *s.maxLength)
+	}
+	if s.pattern != nil && !s.pattern.MatchString(node.Value) {
+		v.report(s, "pattern", node, instancePath, "must match the pattern %q", s.pattern)
+	}
+}
+
+func (v *validator) validateArray(s *schema, node *ast.Array, instancePath string) {
+	if s.minItems != nil && node.Len() < *s.minItems {
+		v.report(s, "minItems", node, instancePath, "must have at least %d items", *s.minItems)
+	}
+	if s.maxItems != nil && node.Len() > *s.maxItems {
+		v.report(s, "maxItems", node, instancePath, "must have at most %d items", *s.maxItems)
+	}
+
+	if s.items != nil {
+		for i, elem := range node.Elements {
+			v.validate(s.items, elem, instancePath+"/"+strconv.Itoa(i))
+		}
+	}
+}
+
+func (v *validator) validateObject(s *schema, node *ast.Object, instancePath string) {
+	for _, name := range s.required {
+		if _, ok := node.Get(name); !ok {
+			v.report(s, "required", node, instancePath, "missing required property %q", name)
+		}
+	}
+
+	for _, m := range node.Members {
+		path := instancePath + "/" + escape(m.Key.Value)
+
+		known := false
+		for _, p := range s.properties {
+			if p.name == m.Key.Value {
+				v.validate(p.schema, m.Value, path)
+				known = true
+			}
+		}
+
+		if known || s.additionalProperties == nil {
+			continue
+		}
+
+		if a := s.additionalProperties; a.always != nil && !*a.always {
+			v.violations = append(v.violations, Violation{
+				InstancePath: path,
+				SchemaPath:   a.path,
+				Pos:          m.Key.Pos(),
+				Msg:          fmt.Sprintf("property %q is not allowed", m.Key.Value),
+			})
+			continue
+		}
+		v.validate(s.additionalProperties, m.Value, path)
+	}
+}
+
+// hasType reports whether node is of one of types, an integer is a number
+// without fractional part
+func hasType(node ast.Node, types []string) bool {
+	for _, t := range types {
+		if t == typeName(node) {
+			return true
+		}
+
+		if n, ok := node.(*ast.Number); ok && t == "integer" {
+			if _, err := n.BigInt(); !errors.Is(err, ast.ErrNotInteger) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// typeName returns the JSON Schema type of node, never integer
+func typeName(node ast.Node) string {
+	return node.Kind().String()
+}
+
+// escape escapes a key as a JSON Pointer token
+func escape(key string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+}
@@ -0,0 +1,216 @@
+package schema
+
+import (
+	"jp/parser"
+	"strings"
+	"testing"
+)
+
+const personSchema = `{
+	"$schema": "https://json-schema.org/draft/2020-12/schema",
+	"type": "object",
+	"required": ["name", "age"],
+	"properties": {
+		"name": {"type": "string", "minLength": 1, "maxLength": 5},
+		"age": {"type": "integer", "minimum": 0, "exclusiveMaximum": 150},
+		"email": {"type": "string", "pattern": "^[^@]+@[^@]+$"},
+		"role": {"enum": ["admin", "user", null]},
+		"version": {"const": 2},
+		"tags": {"type": "array", "items": {"type": "string"}, "minItems": 1, "maxItems": 2},
+		"address": {"$ref": "#/$defs/address"},
+		"friends": {"type": "array", "items": {"$ref": "#"}},
+		"id": {"anyOf": [{"type": "string"}, {"type": "integer", "minimum": 1}]},
+		"score": {"oneOf": [{"type": "number", "maximum": 10}, {"type": "number", "minimum": 5}]},
+		"nick": {"allOf": [{"type": "string"}, {"maxLength": 3}]},
+		"a/b": {"type": ["boolean", "null"]}
+	},
+	"additionalProperties": false,
+	"$defs": {
+		"address": {
+			"type": "object",
+			"properties": {"zip": {"type": "string", "pattern": "^[0-9]{5}$"}},
+			"additionalProperties": {"type": "string"}
+		}
+	}
+}`
+
+func Test_Validate(t *testing.T) {
+	node, err := parser.Parse(personSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := Compile(node)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		doc  string
+		want []string
+	}{
+		{
+			name: "valid",
+			doc: `{"name": "Ann", "age": 30, "email": "ann@example.com", "role": null, "version": 2.0,
+				"tags": ["a"], "address": {"zip": "12345", "city": "Paris"}, "friends": [{"name": "Bob", "age": 1e1}],
+				"id": 3, "score": 2, "nick": "an", "a/b": true}`,
+		},
+		{
+			name: "missing properties",
+			doc:  `{}`,
+			want: []string{
+				`1:1: (root): missing required property "name" (#/required)`,
+				`1:1: (root): missing required property "age" (#/required)`,
+			},
+		},
+		{
+			name: "wrong types",
+			doc:  `{"name": 1, "age": 1.5, "a/b": "x"}`,
+			want: []string{
+				`1:10: /name: must be of type string, not number (#/properties/name/type)`,
+				`1:20: /age: must be of type integer, not number (#/properties/age/type)`,
+				`1:32: /a~1b: must be of type boolean or null, not string (#/properties/a~1b/type)`,
+			},
+		},
+		{
+			name: "integers are exact",
+			doc:  `{"name": "x", "age": 1.0000000000000000001, "id": 123456789012345678901230e-1}`,
+			want: []string{
+				`1:22: /age: must be of type integer, not number (#/properties/age/type)`,
+			},
+		},
+		{
+			name: "bounds",
+			doc:  `{"name": "", "age": 150, "tags": ["a", "b", "c"]}`,
+			want: []string{
+				`1:10: /name: must be at least 1 characters long (#/properties/name/minLength)`,
+				`1:21: /age: must be < 150 (#/properties/age/exclusiveMaximum)`,
+				`1:34: /tags: must have at most 2 items (#/properties/tags/maxItems)`,
+			},
+		},
+		{
+			name: "length in characters",
+			doc:  `{"name": "ÉÉÉÉÉ", "age": -1}`,
+			want: []string{
+				`1:31: /age: must be >= 0 (#/properties/age/minimum)`,
+			},
+		},
+		{
+			name: "enum, const and pattern",
+			doc:  `{"name": "x", "age": 1, "role": "root", "version": 3, "email": "x"}`,
+			want: []string{
+				`1:33: /role: must be one of "admin", "user", null (#/properties/role/enum)`,
+				`1:52: /version: must be 2 (#/properties/version/const)`,
+				`1:64: /email: must match the pattern "^[^@]+@[^@]+$" (#/properties/email/pattern)`,
+			},
+		},
+		{
+			name: "additional properties",
+			doc:  `{"name": "x", "age": 1, "extra": true}`,
+			want: []string{
+				`1:25: /extra: property "extra" is not allowed (#/additionalProperties)`,
+			},
+		},
+		{
+			name: "references",
+			doc:  `{"name": "x", "age": 1, "address": {"zip": "1", "city": 2}, "friends": [{"name": "y"}, {"name": "z", "age": 2, "tags": [1]}]}`,
+			want: []string{
+				`1:44: /address/zip: must match the pattern "^[0-9]{5}$" (#/$defs/address/properties/zip/pattern)`,
+				`1:57: /address/city: must be of type string, not number (#/$defs/address/additionalProperties/type)`,
+				`1:73: /friends/0: missing required property "age" (#/required)`,
+				`1:121: /friends/1/tags/0: must be of type string, not number (#/properties/tags/items/type)`,
+			},
+		},
+		{
+			name: "combinations",
+			doc:  `{"name": "x", "age": 1, "id": 0, "score": 7, "nick": 5}`,
+			want: []string{
+				`1:31: /id: must match at least one schema of anyOf (#/properties/id/anyOf)`,
+				`1:43: /score: must match exactly one schema of oneOf, matches 2 (#/properties/score/oneOf)`,
+				`1:54: /nick: must be of type string, not number (#/properties/nick/allOf/0/type)`,
+			},
+		},
+		{
+			name: "wrong root",
+			doc:  `[]`,
+			want: []string{
+				`1:1: (root): must be of type object, not array (#/type)`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := parser.Parse(tt.doc)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, v := range s.Validate(doc) {
+				got = append(got, v.Error())
+			}
+
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("Validate() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func Test_Compile(t *testing.T) {
+	tests := []struct {
+		name    string
+		schema  string
+		wantErr string
+	}{
+		{name: "booleans", schema: `{"properties": {"a": true, "b": false}}`},
+		{name: "recursive reference", schema: `{"$ref": "#"}`},
+		{name: "escaped reference", schema: `{"$ref": "#/$defs/a~1b%25", "$defs": {"a/b%": {}}}`},
+		{name: "definitions", schema: `{"items": {"$ref": "#/definitions/x"}, "definitions": {"x": {"type": "null"}}}`},
+		{name: "unknown keywords", schema: `{"title": "x", "format": "date", "minimum": 1}`},
+
+		{name: "not a schema", schema: `[1]`, wantErr: "1:1: #: schema must be an object or a boolean"},
+		{name: "unknown type", schema: `{"type": ["string", "text"]}`, wantErr: `1:10: #/type: unknown type "text"`},
+		{name: "bad pattern", schema: `{"pattern": "a("}`, wantErr: "1:13: #/pattern: invalid pattern: error parsing regexp: missing closing ): `a(`"},
+		{name: "bad count", schema: `{"minLength": -1}`, wantErr: "1:15: #/minLength: must be a non negative integer"},
+		{name: "bad bound", schema: `{"maximum": "1"}`, wantErr: "1:13: #/maximum: must be a number"},
+		{name: "bad property", schema: `{"properties": {"a": 1}}`, wantErr: "1:22: #/properties/a: schema must be an object or a boolean"},
+		{name: "empty anyOf", schema: `{"anyOf": []}`, wantErr: "1:11: #/anyOf: anyOf must be a non empty array"},
+		{name: "remote reference", schema: `{"$ref": "other.json"}`, wantErr: `1:10: #/$ref: $ref "other.json" is not local to the schema`},
+		{name: "missing reference", schema: `{"items": {"$ref": "#/$defs/x"}}`, wantErr: `1:20: #/items/$ref: $ref "#/$defs/x" leads nowhere`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node, err := parser.Parse(tt.schema)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = Compile(node)
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+				t.Errorf("Compile() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_ValidateRecursion(t *testing.T) {
+	node, err := parser.Parse(`{"allOf": [{"$ref": "#"}], "items": {"$ref": "#"}, "maxItems": 1}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := Compile(node)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc, err := parser.Parse(`[[[1, 2]]]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := s.Validate(doc)
+	if len(got) != 1 || got[0].InstancePath != "/0/0" || got[0].SchemaPath != "#/maxItems" {
+		t.Errorf("Validate() = %v, want a maxItems violation at /0/0", got)
+	}
+}
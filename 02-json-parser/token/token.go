@@ -1,22 +1,45 @@
 package token
 
+import "fmt"
+
 type TokenType string
 
 type Token struct {
 	Type    TokenType
 	Literal string
+	Pos     Position
+}
+
+// Position is the location of a token or of a node in the source
+type Position struct {
+	Offset int // byte offset, starting at 0
+	Line   int // line number, starting at 1
+	Column int // column number in bytes, starting at 1
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
 }
 
 const (
 	ILLEGAL = "ILLEGAL"
 	EOF     = "EOF"
 
-	KEY   = "KEY"
-	VALUE = "VALUE"
+	STRING = "STRING"
+	NUMBER = "NUMBER"
+	TRUE   = "TRUE"
+	FALSE  = "FALSE"
+	NULL   = "NULL"
+
+	// IDENT is a name other than true, false and null, a member key in JSON5
+	IDENT = "IDENT"
 
 	COLON = ":"
 	COMMA = ","
 
+	// NEWLINE ends a document of newline delimited JSON
+	NEWLINE = "NEWLINE"
+
 	LBRACKET = "["
 	RBRACKET = "]"
 	LBRACE   = "{"
@@ -0,0 +1,305 @@
+package huffman
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	MaxCanonicalCodeBitLen = 15 // The longest canonical code, like deflate
+
+	fastLookupBits = 11 // The codes up to this length are decoded with a single lookup
+)
+
+var (
+	ErrInvalidCodeLengths = fmt.Errorf("invalid code lengths")
+)
+
+// CodeLengths The length of the canonical Huffman code of every byte, 0 for the bytes that do not occur
+// A canonical code is given by its lengths alone: the codes of a length are consecutive,
+// in the order of the bytes, and follow the codes of the shorter lengths
+type CodeLengths [256]uint8
+
+// NewCodeLengths Computes the code lengths of the Huffman tree of freq, at most MaxCanonicalCodeBitLen bits
+// When the tree is deeper, the frequencies are flattened until it is not
+func NewCodeLengths(freq Frequencies) CodeLengths {
+	var lengths CodeLengths
+
+	for {
+		tree := NewHuffmanTree(freq)
+
+		maxLen := 0
+		for _, leaf := range tree.Leaves {
+			l := leafDepth(leaf)
+			lengths[leaf.Byte] = uint8(min(l, 255))
+			maxLen = max(maxLen, l)
+		}
+		if maxLen <= MaxCanonicalCodeBitLen {
+			return lengths
+		}
+
+		flattened := make(Frequencies, len(freq))
+		for k, v := range freq {
+			flattened[k] = v/2 + 1
+		}
+		freq = flattened
+	}
+}
+
+// leafDepth The code length of a leaf, which the HuffmanCode of the leaf truncates to MaxHuffmanCodeBitLen
+func leafDepth(leaf *HuffmanNode) int {
+	depth := 0
+	for cur := leaf; cur.Parent != nil; cur = cur.Parent {
+		depth++
+	}
+
+	return depth
+}
+
+// codes Assigns the canonical codes, the low bits of every uint16
+func (l *CodeLengths) codes() [256]uint16 {
+	var count [MaxCanonicalCodeBitLen + 1]uint16
+	for _, n := range l {
+		count[n]++
+	}
+	count[0] = 0
+
+	var next [MaxCanonicalCodeBitLen + 1]uint16
+	code := uint16(0)
+	for n := 1; n <= MaxCanonicalCodeBitLen; n++ {
+		code = (code + count[n-1]) << 1
+		next[n] = code
+	}
+
+	var codes [256]uint16
+	for b, n := range l {
+		if n != 0 {
+			codes[b] = next[n]
+			next[n]++
+		}
+	}
+
+	return codes
+}
+
+// EncTable Returns the canonical codes as a HuffmanEncTable
+func (l *CodeLengths) EncTable() HuffmanEncTable {
+	codes := l.codes()
+	table := make(HuffmanEncTable)
+
+	for b, n := range l {
+		if n == 0 {
+			continue
+		}
+		code := &HuffmanCode{}
+		for i := int(n) - 1; i >= 0; i-- {
+			if codes[b]>>i&1 == 0 {
+				code.AppendZero()
+			} else {
+				code.AppendOne()
+			}
+		}
+		table[byte(b)] = code
+	}
+
+	return table
+}
+
+// Serialize Serialize the code lengths into a byte slice, at most 257 bytes
+// The serialization format is as follows
+// NUMBER OF LENGTHS - 1	1 byte, the bytes after the last one that occurs are left out
+// LENGTH OF BYTE 0			1 byte
+// ...
+// LENGTH OF BYTE N-1		1 byte
+func (l *CodeLengths) Serialize() []byte {
+	n := len(l)
+	for n > 1 && l[n-1] == 0 {
+		n--
+	}
+
+	ser := make([]byte, 0, 1+n)
+	ser = append(ser, byte(n-1))
+	ser = append(ser, l[:n]...)
+
+	return ser
+}
+
+// DeserializeCodeLengths Deserialize the code lengths, which must be those of a prefix code
+func DeserializeCodeLengths(data []byte) (CodeLengths, error) {
+	var lengths CodeLengths
+	if len(data) == 0 || len(data) != int(data[0])+2 {
+		return lengths, ErrInvalidCodeLengths
+	}
+	copy(lengths[:], data[1:])
+
+	return lengths, lengths.validate()
+}
+
+// validate The code must be a prefix code, with the Kraft sum of its lengths at most 1
+func (l *CodeLengths) validate() error {
+	var kraft uint32
+	for _, n := range l {
+		if n > MaxCanonicalCodeBitLen {
+			return ErrInvalidCodeLengths
+		}
+		if n != 0 {
+			kraft += 1 << (MaxCanonicalCodeBitLen - n)
+		}
+	}
+	if kraft == 0 || kraft > 1<<MaxCanonicalCodeBitLen {
+		return ErrInvalidCodeLengths
+	}
+
+	return nil
+}
+
+// PrettyString Returns the lengths and the codes of the bytes that occur
+func (l *CodeLengths) PrettyString() string {
+	codes := l.codes()
+	prettyStringBuilder := strings.Builder{}
+
+	for b, n := range l {
+		if n != 0 {
+			prettyStringBuilder.WriteString(fmt.Sprintf("%d(%#X)[%c]: %0*b(len=%d)\n", b, b, b, n, codes[b], n))
+		}
+	}
+
+	return prettyStringBuilder.String()
+}
+
+// encodeCanonical Compresses data with the canonical codes of lengths
+// Returns the compressed byte slice and the number of valid bits, the bits are written from the high bit of each byte
+func encodeCanonical(data []byte, lengths *CodeLengths) ([]byte, uint64) {
+	codes := lengths.codes()
+
+	var bitLen uint64
+	for _, b := range data {
+		bitLen += uint64(lengths[b])
+	}
+	out := make([]byte, 0, (bitLen+7)/8)
+
+	// acc holds the pending bits in its low nacc bits, fewer than 8 between two bytes
+	var acc uint64
+	var nacc uint
+	for _, b := range data {
+		n := uint(lengths[b])
+		acc = acc<<n | uint64(codes[b])
+		nacc += n
+		for nacc >= 8 {
+			nacc -= 8
+			out = append(out, byte(acc>>nacc))
+		}
+	}
+	if nacc > 0 {
+		out = append(out, byte(acc<<(8-nacc)))
+	}
+
+	return out, bitLen
+}
+
+// canonicalDecoder Decodes canonical codes several bits at a time
+type canonicalDecoder struct {
+	// The byte and the code length of every fastLookupBits bits that start with a code of at most that length,
+	// the length in the high 8 bits, 0 for the longer codes
+	fast [1 << fastLookupBits]uint16
+
+	// The longer codes are found from the first code and the number of codes of each length
+	first   [MaxCanonicalCodeBitLen + 1]uint32
+	count   [MaxCanonicalCodeBitLen + 1]uint32
+	offset  [MaxCanonicalCodeBitLen + 1]uint32 // index in symbols of the first code of each length
+	symbols []byte                             // the bytes in the order of their codes
+}
+
+func newCanonicalDecoder(lengths *CodeLengths) *canonicalDecoder {
+	d := &canonicalDecoder{}
+	codes := lengths.codes()
+
+	for _, n := range lengths {
+		if n != 0 {
+			d.count[n]++
+		}
+	}
+
+	code, index := uint32(0), uint32(0)
+	for n := 1; n <= MaxCanonicalCodeBitLen; n++ {
+		code = (code + d.count[n-1]) << 1
+		d.first[n] = code
+		d.offset[n] = index
+		index += d.count[n]
+	}
+
+	d.symbols = make([]byte, index)
+	next := d.offset
+	for b, n := range lengths {
+		if n == 0 {
+			continue
+		}
+		d.symbols[next[n]] = byte(b)
+		next[n]++
+
+		if n <= fastLookupBits {
+			// Every entry that starts with the code
+			shift := fastLookupBits - uint(n)
+			start := uint32(codes[b]) << shift
+			for i := start; i < start+1<<shift; i++ {
+				d.fast[i] = uint16(n)<<8 | uint16(b)
+			}
+		}
+	}
+
+	return d
+}
+
+// decode Decodes size bytes from the bitLen valid bits of data, which must all be used
+func (d *canonicalDecoder) decode(data []byte, bitLen uint64, size int) ([]byte, error) {
+	out := make([]byte, size)
+
+	// acc holds nacc bits from its high bit, the bits past the end of data are zeros
+	// loaded counts the bits put in acc, the bits used are checked once at the end
+	var acc, loaded uint64
+	var nacc uint
+	pos := 0
+
+	for i := range out {
+		if nacc < MaxCanonicalCodeBitLen {
+			for nacc <= 56 {
+				if pos < len(data) {
+					acc |= uint64(data[pos]) << (56 - nacc)
+					pos++
+				}
+				nacc += 8
+				loaded += 8
+			}
+		}
+
+		var n uint
+		if e := d.fast[acc>>(64-fastLookupBits)]; e != 0 {
+			n = uint(e >> 8)
+			out[i] = byte(e)
+		} else {
+			for n = fastLookupBits + 1; n <= MaxCanonicalCodeBitLen; n++ {
+				k := uint32(acc>>(64-n)) - d.first[n]
+				if k < d.count[n] {
+					out[i] = d.symbols[d.offset[n]+k]
+					break
+				}
+			}
+			if n > MaxCanonicalCodeBitLen {
+				return nil, ErrBitCodeNotFound
+			}
+		}
+
+		acc <<= n
+		nacc -= n
+	}
+
+	used := loaded - uint64(nacc)
+	if used > bitLen {
+		return nil, ErrBitsExhausted
+	}
+	if used < bitLen {
+		return nil, ErrCorruptBlock
+	}
+
+	return out, nil
+}
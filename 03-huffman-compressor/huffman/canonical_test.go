@@ -0,0 +1,154 @@
+package huffman
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodeLengths_Canonical(t *testing.T) {
+	freq := Frequencies{'a': 45, 'b': 13, 'c': 12, 'd': 16, 'e': 9, 'f': 5}
+	lengths := NewCodeLengths(freq)
+
+	// The lengths of the Huffman tree, the codes follow from them alone
+	require.Equal(t, uint8(1), lengths['a'])
+	require.Equal(t, uint8(3), lengths['b'])
+	require.Equal(t, uint8(3), lengths['c'])
+	require.Equal(t, uint8(3), lengths['d'])
+	require.Equal(t, uint8(4), lengths['e'])
+	require.Equal(t, uint8(4), lengths['f'])
+
+	table := lengths.EncTable()
+	require.Equal(t, "0", table['a'].String())
+	require.Equal(t, "100", table['b'].String())
+	require.Equal(t, "101", table['c'].String())
+	require.Equal(t, "110", table['d'].String())
+	require.Equal(t, "1110", table['e'].String())
+	require.Equal(t, "1111", table['f'].String())
+
+	require.Contains(t, lengths.PrettyString(), "101(0X65)[e]: 1110(len=4)\n")
+}
+
+func TestCodeLengths_OneByte(t *testing.T) {
+	lengths := NewCodeLengths(Frequencies{'x': 10})
+
+	require.Equal(t, uint8(1), lengths['x'])
+	require.Equal(t, "0", lengths.EncTable()['x'].String())
+}
+
+func TestCodeLengths_Limited(t *testing.T) {
+	// Fibonacci frequencies make a tree of depth 39, as deep as it gets
+	freq := make(Frequencies)
+	a, b := uint64(1), uint64(1)
+	for i := 0; i < 40; i++ {
+		freq[byte(i)] = a
+		a, b = b, a+b
+	}
+
+	lengths := NewCodeLengths(freq)
+	for k := range freq {
+		require.LessOrEqual(t, lengths[k], uint8(MaxCanonicalCodeBitLen))
+		require.NotZero(t, lengths[k])
+	}
+	require.Nil(t, lengths.validate())
+
+	// Bytes of every code length round trip
+	var data []byte
+	for k, v := range freq {
+		data = append(data, bytes.Repeat([]byte{k}, int(min(v, 1000)))...)
+	}
+	compressed, bitLen := encodeCanonical(data, &lengths)
+	got, err := newCanonicalDecoder(&lengths).decode(compressed, bitLen, len(data))
+	require.Nil(t, err)
+	require.Equal(t, data, got)
+}
+
+func TestCodeLengths_SerializeAndDeserialize(t *testing.T) {
+	lengths := NewCodeLengths(CountFrequencies([]byte("abracadabra")))
+
+	ser := lengths.Serialize()
+	// 'r' is the last byte that occurs
+	require.Equal(t, byte('r'), ser[0])
+	require.Len(t, ser, 'r'+2)
+
+	got, err := DeserializeCodeLengths(ser)
+	require.Nil(t, err)
+	require.Equal(t, lengths, got)
+
+	var all CodeLengths
+	for i := range all {
+		all[i] = 8
+	}
+	require.Len(t, all.Serialize(), 257)
+
+	invalid := map[string][]byte{
+		"empty":      {},
+		"short":      {3, 1, 1},
+		"no code":    {1, 0, 0},
+		"too long":   {0, MaxCanonicalCodeBitLen + 1},
+		"not prefix": {2, 1, 1, 1},
+	}
+	for name, data := range invalid {
+		_, err := DeserializeCodeLengths(data)
+		require.ErrorIs(t, err, ErrInvalidCodeLengths, name)
+	}
+}
+
+func TestCanonicalDecoder_Corrupted(t *testing.T) {
+	// An incomplete code: 1 is not a code
+	lengths := CodeLengths{'a': 1}
+	d := newCanonicalDecoder(&lengths)
+
+	got, err := d.decode([]byte{0x00}, 3, 3)
+	require.Nil(t, err)
+	require.Equal(t, "aaa", string(got))
+
+	_, err = d.decode([]byte{0x40}, 3, 3)
+	require.ErrorIs(t, err, ErrBitCodeNotFound)
+
+	_, err = d.decode([]byte{0x00}, 2, 3)
+	require.ErrorIs(t, err, ErrBitsExhausted)
+
+	_, err = d.decode([]byte{0x00}, 4, 3)
+	require.ErrorIs(t, err, ErrCorruptBlock)
+}
+
+func benchmarkData(b *testing.B) []byte {
+	data, err := os.ReadFile("../test/test_data1.txt")
+	require.Nil(b, err)
+
+	return data[:1<<20]
+}
+
+func BenchmarkDecode_Canonical(b *testing.B) {
+	data := benchmarkData(b)
+	lengths := NewCodeLengths(CountFrequencies(data))
+	compressed, bitLen := encodeCanonical(data, &lengths)
+
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := newCanonicalDecoder(&lengths).decode(compressed, bitLen, len(data))
+		require.Nil(b, err)
+	}
+}
+
+func BenchmarkDecode_Table(b *testing.B) {
+	data := benchmarkData(b)
+	encTable := NewHuffmanEncTable(NewHuffmanTree(CountFrequencies(data)))
+	compressed, bitLen, err := compressBytesWith(data, encTable)
+	require.Nil(b, err)
+	ser, err := encTable.Serialize()
+	require.Nil(b, err)
+	decTable, err := DeserializeHuffmanDecTable(ser)
+	require.Nil(b, err)
+
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := decompressBytesWith(compressed, bitLen, decTable)
+		require.Nil(b, err)
+	}
+}
@@ -6,7 +6,6 @@ import (
 	"io"
 	"log"
 	"os"
-	"path"
 )
 
 const (
@@ -53,109 +52,42 @@ func CompressBytes(data []byte) ([]byte, uint64, error) {
 	return compressBytesWith(data, table)
 }
 
-// CompressFile Compress a file
-// Compress the src file and write it to a DST file
-//
-// The compressed file format is as follows: (big-endian)
-// HEADER
-//   - START_FLAG						2 bytes (uint16)
-//   - SRC_FILENAME_LEN					2 bytes (uint16)
-//   - BYTE SIZE BEFORE COMPRESSION		4 bytes (uint32)
-//   - BYTE SIZE AFTER COMPRESSION		4 bytes (uint32)
-//   - SRC_FILENAME						n bytes
-//
-// DATA
-//   - HUFFMAN TABLE
-//     -- HUFFMAN TABLE SIZE 	4 bytes (uint32)
-//     -- HUFFMAN TABLE DATA
-//   - COMPRESSED DATA
-//     -- VALID BIT LEN			4 bytes (uint32) + 1 bytes = 5 bytes
-//     -- COMPRESSED BIT
-//
-// TAIL
-//   - CRC32 CHECKSUM	  	4 bytes (uint32)
-//   - END_FLAG				2 bytes (uint16)
-func CompressFile(src, dst string) error {
-	srcF, err := os.Open(src)
-	if err != nil {
-		return err
-	}
-	defer srcF.Close()
+// Compress Compresses src into dst as a block-framed stream, see Writer for the format
+// Returns the number of bytes read from src
+func Compress(dst io.Writer, src io.Reader) (int64, error) {
+	z := NewWriter(dst)
 
-	// Read in the source file content
-	allSrcBytes, err := io.ReadAll(srcF)
+	n, err := io.Copy(z, src)
 	if err != nil {
-		return err
+		return n, err
 	}
 
-	// Perform source file compression
-	freq := CountFrequencies(allSrcBytes)
-	tree := NewHuffmanTree(freq)
-	encTable := NewHuffmanEncTable(tree)
-
-	compressedBytes, bitLen, err := compressBytesWith(allSrcBytes, encTable)
-	if err != nil {
-		return err
-	}
+	return n, z.Close()
+}
 
-	// Huffman Computer
-	encTableSer, err := encTable.Serialize()
+// CompressFile Compress a file
+// Compress the src file and write it to a dst file, a block at a time
+func CompressFile(src, dst string) error {
+	srcF, err := os.Open(src)
 	if err != nil {
 		return err
 	}
+	defer srcF.Close()
 
-	// Prepare to write to the target file
 	dstF, err := os.Create(dst)
 	if err != nil {
 		return err
 	}
 	defer dstF.Close()
 
-	originalSize := uint32(len(allSrcBytes))
-	compressedSize := uint32(len(compressedBytes))
-	filenameNoDir := path.Base(src)
-	filenameNoDirSize := uint16(len(filenameNoDir))
-
-	// 27 bytes is a fixed cost for the file
-	var alloc uint32 = 27 + compressedSize + uint32(len(encTableSer))
-	dstBytes := make([]byte, 0, alloc)
-	// Write to the file header
-	dstBytes = writeUint16ToBytes(CompressedFileStartFlag, dstBytes) // The file begins to be identified
-	dstBytes = writeUint16ToBytes(filenameNoDirSize, dstBytes)       // The length of the file name
-	dstBytes = writeUint32ToBytes(originalSize, dstBytes)            // Byte size before compression
-	dstBytes = writeUint32ToBytes(compressedSize, dstBytes)          // Byte size after compression
-	dstBytes = append(dstBytes, []byte(filenameNoDir)...)            // The name of the source file
-
-	// Write to the datazone
-	dstBytes = writeUint32ToBytes(uint32(len(encTableSer)), dstBytes) // Huffman computer size
-	dstBytes = append(dstBytes, encTableSer...)                       // Huffman Computer
-
-	// Calculate how many bytes will be used after compression based on the actual bit length
-	bytesNeededAfterCompressed := bitLen / 8
-	slot := bitLen % 8
-	if slot != 0 {
-		bytesNeededAfterCompressed += 1
+	if _, err := Compress(dstF, srcF); err != nil {
+		return err
 	}
 
-	// Use 5 bytes to record bitLen:
-	// bytesNeededAfterCompressed with 4 bytes
-	// slot with 1 byte
-	dstBytes = writeUint32ToBytes(uint32(bytesNeededAfterCompressed), dstBytes)
-	dstBytes = append(dstBytes, byte(slot))
-	// dstBytes = writeUint64ToBytes(bitLen, dstBytes) // bitlen
-	dstBytes = append(dstBytes, compressedBytes...) // The compressed data itself
-
-	// Write to the end of the file
-	checksum := crc32.Checksum(dstBytes, crc32q)
-	dstBytes = writeUint32ToBytes(checksum, dstBytes)              // checksum
-	dstBytes = writeUint16ToBytes(CompressedFileEndFlag, dstBytes) // End tag
-
-	// Write files all at once
-	n, err := dstF.Write(dstBytes)
+	n, err := dstF.Seek(0, io.SeekCurrent)
 	if err != nil {
 		return err
 	}
-
 	log.Printf("successfully written %d bytes into %s\n", n, dst)
 
 	return nil
@@ -179,6 +111,17 @@ func DecompressBytes(data []byte, bitLen uint64, table HuffmanDecTable) ([]byte,
 	return decompressBytesWith(data, bitLen, table)
 }
 
+// Decompress Decompresses the stream src into dst, files of the first format included
+// Returns the number of bytes written to dst
+func Decompress(dst io.Writer, src io.Reader) (int64, error) {
+	z, err := NewReader(src)
+	if err != nil {
+		return 0, err
+	}
+
+	return io.Copy(dst, z)
+}
+
 // DecompressFile Decompress a file
 // Extract the src file and write it to a dst file
 func DecompressFile(src, dst string) error {
@@ -188,46 +131,66 @@ func DecompressFile(src, dst string) error {
 	}
 	defer srcF.Close()
 
-	srcBytes, err := io.ReadAll(srcF)
+	// Create an object file to prepare for writeback
+	dstF, err := os.Create(dst)
 	if err != nil {
 		return err
 	}
+	defer dstF.Close()
+
+	n, err := Decompress(dstF, srcF)
+	if err != nil {
+		return err
+	}
+	log.Printf("successfully written %d bytes into destination: %s\n", n, dst)
+
+	return nil
+}
 
+// decompressLegacy Decompress a file of the first format, read in whole
+//
+// The format is as follows: (big-endian)
+// HEADER
+//   - START_FLAG						2 bytes (uint16)
+//   - SRC_FILENAME_LEN					2 bytes (uint16)
+//   - BYTE SIZE BEFORE COMPRESSION		4 bytes (uint32)
+//   - BYTE SIZE AFTER COMPRESSION		4 bytes (uint32)
+//   - SRC_FILENAME						n bytes
+//
+// DATA
+//   - HUFFMAN TABLE
+//     -- HUFFMAN TABLE SIZE 	4 bytes (uint32)
+//     -- HUFFMAN TABLE DATA
+//   - COMPRESSED DATA
+//     -- VALID BIT LEN			4 bytes (uint32) + 1 bytes = 5 bytes
+//     -- COMPRESSED BIT
+//
+// TAIL
+//   - CRC32 CHECKSUM	  	4 bytes (uint32)
+//   - END_FLAG				2 bytes (uint16)
+func decompressLegacy(srcBytes []byte) ([]byte, error) {
 	// Parse the compressed bytes of the source file
 	cursor := 0
 	// File header
-	cursor, err = parseFileHeader(srcBytes, cursor)
+	cursor, err := parseFileHeader(srcBytes, cursor)
 	if err != nil {
-		return fmt.Errorf("can not parse file header: %v", err)
+		return nil, fmt.Errorf("can not parse file header: %v", err)
 	}
 
 	// Data area
 	decompressedBytes, cursor, err := parseCompressedDataArea(srcBytes, cursor)
 	if err != nil {
-		return fmt.Errorf("can not parse file data area: %v", err)
+		return nil, fmt.Errorf("can not parse file data area: %v", err)
 	}
 
 	// Tail of file
 	// Check whether the data is correct
 	_, err = parseFileTail(srcBytes, cursor)
 	if err != nil {
-		return fmt.Errorf("can not parse file tail: %v", err)
-	}
-
-	// Create an object file to prepare for writeback
-	dstF, err := os.Create(dst)
-	if err != nil {
-		return err
+		return nil, fmt.Errorf("can not parse file tail: %v", err)
 	}
-	defer dstF.Close()
 
-	n, err := dstF.Write(decompressedBytes)
-	if err != nil {
-		return err
-	}
-	log.Printf("successfully written %d bytes into destination: %s\n", n, dst)
-
-	return nil
+	return decompressedBytes, nil
 }
 
 // Parse the compressed file header
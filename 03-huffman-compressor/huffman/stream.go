@@ -0,0 +1,440 @@
+package huffman
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+const (
+	StreamMagic   uint32 = 0x48465354 // "HFST"
+	StreamVersion byte   = 2          // 1: the blocks have a serialized HuffmanEncTable, 2: the code lengths of canonical codes
+
+	DefaultBlockSize = 1 << 20  // bytes
+	MaxBlockSize     = 64 << 20 // bytes
+
+	streamHeaderSize = Uint32ByteSize + 1
+	maxTableSerSize  = MinHuffmanTableSerSize + 256*TableItemSize // in the blocks of version 1
+)
+
+var (
+	ErrInvalidBlockSize   = fmt.Errorf("block size must be between 1 and %d bytes", MaxBlockSize)
+	ErrUnsupportedVersion = fmt.Errorf("unsupported stream version")
+	ErrCorruptBlock       = fmt.Errorf("corrupt block")
+	ErrSizeNotMatched     = fmt.Errorf("size not matched")
+	ErrWriterClosed       = fmt.Errorf("write to a closed writer")
+)
+
+// Writer Compresses the bytes written to it into a block-framed stream
+// The input is cut into blocks of blockSize bytes, every block is compressed with its own canonical Huffman code,
+// so memory use depends on the block size only, whatever the size of the input
+//
+// The stream format is as follows: (big-endian)
+// HEADER
+//   - STREAM_MAGIC						4 bytes (uint32)
+//   - VERSION							1 byte
+//
+// BLOCK, repeated
+//   - BYTE SIZE BEFORE COMPRESSION		4 bytes (uint32), never 0
+//   - CODE LENGTHS						2 to 257 bytes, see CodeLengths.Serialize
+//   - VALID BIT LEN					8 bytes (uint64)
+//   - COMPRESSED BIT					(VALID BIT LEN + 7) / 8 bytes
+//   - CRC32 CHECKSUM					4 bytes (uint32), of the block before compression
+//   - in version 1, HUFFMAN TABLE SIZE	4 bytes (uint32) and a serialized HuffmanEncTable instead of CODE LENGTHS
+//
+// TAIL
+//   - 0								4 bytes (uint32), where the next block would start
+//   - BYTE SIZE BEFORE COMPRESSION		8 bytes (uint64), of the whole stream
+//   - END_FLAG							2 bytes (uint16)
+type Writer struct {
+	w           io.Writer
+	buf         []byte // the bytes of the current block
+	blockSize   int
+	total       uint64 // the bytes written in the blocks so far
+	wroteHeader bool
+	closed      bool
+	err         error
+}
+
+// NewWriter Returns a Writer compressing to w with blocks of DefaultBlockSize bytes
+// The stream is complete once the Writer is closed
+func NewWriter(w io.Writer) *Writer {
+	z, _ := NewWriterSize(w, DefaultBlockSize)
+	return z
+}
+
+// NewWriterSize Returns a Writer compressing to w with blocks of blockSize bytes
+func NewWriterSize(w io.Writer, blockSize int) (*Writer, error) {
+	if blockSize <= 0 || blockSize > MaxBlockSize {
+		return nil, ErrInvalidBlockSize
+	}
+
+	return &Writer{
+		w:         w,
+		buf:       make([]byte, 0, min(blockSize, DefaultBlockSize)),
+		blockSize: blockSize,
+	}, nil
+}
+
+// Write Buffers p and writes the blocks it completes
+func (z *Writer) Write(p []byte) (int, error) {
+	if z.err != nil {
+		return 0, z.err
+	}
+	if z.closed {
+		return 0, ErrWriterClosed
+	}
+
+	n := 0
+	for len(p) > 0 {
+		k := min(z.blockSize-len(z.buf), len(p))
+		z.buf = append(z.buf, p[:k]...)
+		p = p[k:]
+		n += k
+
+		if len(z.buf) == z.blockSize {
+			if err := z.writeBlock(); err != nil {
+				return n, err
+			}
+		}
+	}
+
+	return n, nil
+}
+
+// Flush Writes the buffered bytes as a block, even if it is not full
+func (z *Writer) Flush() error {
+	if z.err != nil {
+		return z.err
+	}
+	if z.closed {
+		return ErrWriterClosed
+	}
+
+	return z.writeBlock()
+}
+
+// Close Writes the last block and the tail of the stream
+// The underlying writer is not closed
+func (z *Writer) Close() error {
+	if z.closed {
+		return z.err
+	}
+	if err := z.Flush(); err != nil {
+		return err
+	}
+	z.closed = true
+
+	tail := make([]byte, 0, Uint32ByteSize+Uint64ByteSize+Uint16ByteSize)
+	tail = writeUint32ToBytes(0, tail)
+	tail = writeUint64ToBytes(z.total, tail)
+	tail = writeUint16ToBytes(CompressedFileEndFlag, tail)
+
+	return z.write(tail)
+}
+
+// writeBlock Compresses the buffered bytes into a block, the header of the stream goes first
+func (z *Writer) writeBlock() error {
+	if !z.wroteHeader {
+		z.wroteHeader = true
+
+		header := make([]byte, 0, streamHeaderSize)
+		header = writeUint32ToBytes(StreamMagic, header)
+		header = append(header, StreamVersion)
+		if err := z.write(header); err != nil {
+			return err
+		}
+	}
+
+	if len(z.buf) == 0 {
+		return nil
+	}
+
+	lengths := NewCodeLengths(CountFrequencies(z.buf))
+	compressedBytes, bitLen := encodeCanonical(z.buf, &lengths)
+	lengthsSer := lengths.Serialize()
+
+	block := make([]byte, 0, 2*Uint32ByteSize+Uint64ByteSize+len(lengthsSer)+len(compressedBytes))
+	block = writeUint32ToBytes(uint32(len(z.buf)), block)
+	block = append(block, lengthsSer...)
+	block = writeUint64ToBytes(bitLen, block)
+	block = append(block, compressedBytes...)
+	block = writeUint32ToBytes(crc32.Checksum(z.buf, crc32q), block)
+
+	z.total += uint64(len(z.buf))
+	z.buf = z.buf[:0]
+
+	return z.write(block)
+}
+
+func (z *Writer) write(p []byte) error {
+	if _, err := z.w.Write(p); err != nil {
+		z.err = err
+		return err
+	}
+
+	return nil
+}
+
+// Reader Decompresses a stream written by Writer, of any version, or several concatenated streams
+// The compressed files of the first format, a single block read at once, are decompressed too
+type Reader struct {
+	r       *bufio.Reader
+	version byte
+	block   []byte // the decompressed bytes not read yet
+	total   uint64 // the bytes decompressed so far in the current stream
+	done    bool
+	err     error
+}
+
+// NewReader Returns a Reader decompressing r, after checking the header of the stream
+func NewReader(r io.Reader) (*Reader, error) {
+	z := &Reader{r: bufio.NewReader(r)}
+
+	flag, err := z.r.Peek(Uint16ByteSize)
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	if uint16(flag[0])<<8|uint16(flag[1]) == CompressedFileStartFlag {
+		return z.readLegacy()
+	}
+
+	if err := z.readHeader(); err != nil {
+		return nil, err
+	}
+
+	return z, nil
+}
+
+// readHeader Checks the header of a stream and starts counting its bytes
+func (z *Reader) readHeader() error {
+	var header [streamHeaderSize]byte
+	if _, err := io.ReadFull(z.r, header[:]); err != nil {
+		return unexpectedEOF(err)
+	}
+	magic, _ := readNextUint32(header[:], 0)
+	if magic != StreamMagic {
+		return ErrInvalidStartFlag
+	}
+	z.version = header[Uint32ByteSize]
+	if z.version == 0 || z.version > StreamVersion {
+		return ErrUnsupportedVersion
+	}
+	z.total = 0
+
+	return nil
+}
+
+// Read Reads decompressed bytes, the blocks are decompressed one at a time
+func (z *Reader) Read(p []byte) (int, error) {
+	for len(z.block) == 0 {
+		if z.err != nil {
+			return 0, z.err
+		}
+		if z.done {
+			return 0, io.EOF
+		}
+		z.err = z.readBlock()
+	}
+
+	n := copy(p, z.block)
+	z.block = z.block[n:]
+
+	return n, nil
+}
+
+// readBlock Decompresses the next block, or checks the tail of the stream and the header of the next one
+func (z *Reader) readBlock() error {
+	size, err := z.readUint32()
+	if err != nil {
+		return err
+	}
+
+	if size == 0 {
+		total, err := z.readUint64()
+		if err != nil {
+			return err
+		}
+		if total != z.total {
+			return ErrSizeNotMatched
+		}
+		endFlag, err := z.readUint16()
+		if err != nil {
+			return err
+		}
+		if endFlag != CompressedFileEndFlag {
+			return ErrInvalidEndFlag
+		}
+
+		// Another stream may follow, the concatenated streams are decompressed one after the other, like gzip members
+		if _, err := z.r.Peek(1); err != nil {
+			if err != io.EOF {
+				return err
+			}
+			z.done = true
+			return nil
+		}
+		return z.readHeader()
+	}
+
+	if size > MaxBlockSize {
+		return ErrCorruptBlock
+	}
+
+	var block []byte
+	if z.version == 1 {
+		block, err = z.readTableBlock(size)
+	} else {
+		block, err = z.readCanonicalBlock(size)
+	}
+	if err != nil {
+		return err
+	}
+
+	checksum, err := z.readUint32()
+	if err != nil {
+		return err
+	}
+	if crc32.Checksum(block, crc32q) != checksum {
+		return ErrChecksumNotMatched
+	}
+
+	z.total += uint64(size)
+	z.block = block
+
+	return nil
+}
+
+// readCanonicalBlock Decompresses the code lengths and the bits of a block of version 2
+func (z *Reader) readCanonicalBlock(size uint32) ([]byte, error) {
+	n, err := z.r.ReadByte()
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	lengthsSer := make([]byte, int(n)+2)
+	lengthsSer[0] = n
+	if _, err := io.ReadFull(z.r, lengthsSer[1:]); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	lengths, err := DeserializeCodeLengths(lengthsSer)
+	if err != nil {
+		return nil, err
+	}
+
+	compressedBytes, bitLen, err := z.readBits(size, MaxCanonicalCodeBitLen)
+	if err != nil {
+		return nil, err
+	}
+
+	return newCanonicalDecoder(&lengths).decode(compressedBytes, bitLen, int(size))
+}
+
+// readTableBlock Decompresses the Huffman table and the bits of a block of version 1
+func (z *Reader) readTableBlock(size uint32) ([]byte, error) {
+	tableSize, err := z.readUint32()
+	if err != nil {
+		return nil, err
+	}
+	if tableSize > maxTableSerSize {
+		return nil, ErrCorruptBlock
+	}
+	tableSer := make([]byte, tableSize)
+	if _, err := io.ReadFull(z.r, tableSer); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	decTable, err := DeserializeHuffmanDecTable(tableSer)
+	if err != nil {
+		return nil, err
+	}
+
+	compressedBytes, bitLen, err := z.readBits(size, MaxHuffmanCodeBitLen)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := decompressBytesWith(compressedBytes, bitLen, decTable)
+	if err != nil {
+		return nil, err
+	}
+	if len(block) != int(size) {
+		return nil, ErrCorruptBlock
+	}
+
+	return block, nil
+}
+
+// readBits Reads the valid bit length and the compressed bits of a block of size bytes
+// Every byte takes 1 to maxBitLen bits
+func (z *Reader) readBits(size uint32, maxBitLen uint64) ([]byte, uint64, error) {
+	bitLen, err := z.readUint64()
+	if err != nil {
+		return nil, 0, err
+	}
+	if bitLen < uint64(size) || bitLen > uint64(size)*maxBitLen {
+		return nil, 0, ErrCorruptBlock
+	}
+
+	compressedBytes := make([]byte, (bitLen+7)/8)
+	if _, err := io.ReadFull(z.r, compressedBytes); err != nil {
+		return nil, 0, unexpectedEOF(err)
+	}
+
+	return compressedBytes, bitLen, nil
+}
+
+// readLegacy Decompresses a file of the first format all at once, its sizes are 32 bits so it fits in memory
+func (z *Reader) readLegacy() (*Reader, error) {
+	srcBytes, err := io.ReadAll(z.r)
+	if err != nil {
+		return nil, err
+	}
+
+	decompressedBytes, err := decompressLegacy(srcBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	z.block = decompressedBytes
+	z.total = uint64(len(decompressedBytes))
+	z.done = true
+
+	return z, nil
+}
+
+func (z *Reader) readUint16() (uint16, error) {
+	var buf [Uint16ByteSize]byte
+	if _, err := io.ReadFull(z.r, buf[:]); err != nil {
+		return 0, unexpectedEOF(err)
+	}
+
+	return readNextUint16(buf[:], 0)
+}
+
+func (z *Reader) readUint32() (uint32, error) {
+	var buf [Uint32ByteSize]byte
+	if _, err := io.ReadFull(z.r, buf[:]); err != nil {
+		return 0, unexpectedEOF(err)
+	}
+
+	return readNextUint32(buf[:], 0)
+}
+
+func (z *Reader) readUint64() (uint64, error) {
+	var buf [Uint64ByteSize]byte
+	if _, err := io.ReadFull(z.r, buf[:]); err != nil {
+		return 0, unexpectedEOF(err)
+	}
+
+	return readNextUint64(buf[:], 0)
+}
+
+// unexpectedEOF The stream can not end before its tail
+func unexpectedEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+
+	return err
+}
@@ -0,0 +1,197 @@
+package huffman
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func compressWithBlockSize(t *testing.T, data []byte, blockSize int) []byte {
+	var buf bytes.Buffer
+	z, err := NewWriterSize(&buf, blockSize)
+	require.Nil(t, err)
+
+	// Write in small pieces, not aligned with the blocks
+	for len(data) > 0 {
+		n := min(len(data), 1000)
+		_, err := z.Write(data[:n])
+		require.Nil(t, err)
+		data = data[n:]
+	}
+	require.Nil(t, z.Close())
+
+	return buf.Bytes()
+}
+
+func decompressAll(data []byte) ([]byte, error) {
+	z, err := NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	return io.ReadAll(z)
+}
+
+func TestStream_RoundTrip(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+
+	random := make([]byte, 100000)
+	rnd.Read(random)
+
+	// Skewed bytes, where the codes are of many lengths
+	skewed := make([]byte, 100000)
+	for i := range skewed {
+		skewed[i] = byte(rnd.ExpFloat64() * 8)
+	}
+
+	inputs := map[string][]byte{
+		"empty":  {},
+		"one":    {'a'},
+		"same":   bytes.Repeat([]byte{'x'}, 5000),
+		"text":   bytes.Repeat([]byte("the quick brown fox jumps over the lazy dog\n"), 1000),
+		"random": random,
+		"skewed": skewed,
+		"allbyte": func() []byte {
+			b := make([]byte, 256)
+			for i := range b {
+				b[i] = byte(i)
+			}
+			return b
+		}(),
+	}
+
+	for name, data := range inputs {
+		for _, blockSize := range []int{1, 7, 4096, DefaultBlockSize} {
+			if blockSize == 1 && len(data) > 5000 {
+				continue
+			}
+
+			compressed := compressWithBlockSize(t, data, blockSize)
+			got, err := decompressAll(compressed)
+			require.Nil(t, err, "%s with blocks of %d bytes", name, blockSize)
+			require.Equal(t, data, append([]byte{}, got...), "%s with blocks of %d bytes", name, blockSize)
+		}
+	}
+}
+
+func TestStream_Flush(t *testing.T) {
+	var buf bytes.Buffer
+	z := NewWriter(&buf)
+
+	_, err := z.Write([]byte("hello "))
+	require.Nil(t, err)
+	require.Nil(t, z.Flush())
+	require.NotZero(t, buf.Len())
+
+	_, err = z.Write([]byte("world"))
+	require.Nil(t, err)
+	require.Nil(t, z.Close())
+
+	_, err = z.Write([]byte("!"))
+	require.ErrorIs(t, err, ErrWriterClosed)
+
+	got, err := decompressAll(buf.Bytes())
+	require.Nil(t, err)
+	require.Equal(t, "hello world", string(got))
+}
+
+func TestStream_InvalidBlockSize(t *testing.T) {
+	_, err := NewWriterSize(io.Discard, 0)
+	require.ErrorIs(t, err, ErrInvalidBlockSize)
+
+	_, err = NewWriterSize(io.Discard, MaxBlockSize+1)
+	require.ErrorIs(t, err, ErrInvalidBlockSize)
+}
+
+func TestStream_Corrupted(t *testing.T) {
+	data := bytes.Repeat([]byte("abracadabra "), 1000)
+	compressed := compressWithBlockSize(t, data, 4096)
+
+	// A byte in the middle of the compressed bits of the first block
+	flipped := append([]byte{}, compressed...)
+	flipped[len(flipped)/4] ^= 0x10
+	_, err := decompressAll(flipped)
+	require.NotNil(t, err)
+
+	// A stream cut before its tail
+	for _, n := range []int{0, 3, streamHeaderSize, len(compressed) / 2, len(compressed) - 1} {
+		_, err := decompressAll(compressed[:n])
+		require.ErrorIs(t, err, io.ErrUnexpectedEOF, "cut at %d", n)
+	}
+
+	// The total size in the tail
+	tail := append([]byte{}, compressed...)
+	tail[len(tail)-Uint16ByteSize-1]++
+	_, err = decompressAll(tail)
+	require.ErrorIs(t, err, ErrSizeNotMatched)
+
+	// The header
+	_, err = decompressAll([]byte("HFSX\x01"))
+	require.ErrorIs(t, err, ErrInvalidStartFlag)
+	_, err = decompressAll([]byte("HFST\x09"))
+	require.ErrorIs(t, err, ErrUnsupportedVersion)
+}
+
+func TestStream_Concatenated(t *testing.T) {
+	a := compressWithBlockSize(t, []byte("first stream\n"), 4)
+	b := compressWithBlockSize(t, []byte("second stream\n"), DefaultBlockSize)
+	empty := compressWithBlockSize(t, nil, DefaultBlockSize)
+
+	// Like cat a.hf b.hf, the streams are decompressed one after the other
+	got, err := decompressAll(append(append(append([]byte{}, a...), empty...), b...))
+	require.Nil(t, err)
+	require.Equal(t, "first stream\nsecond stream\n", string(got))
+
+	// Bytes after a stream must be another stream
+	_, err = decompressAll(append(append([]byte{}, a...), "junk!"...))
+	require.ErrorIs(t, err, ErrInvalidStartFlag)
+
+	// A second stream cut before its tail
+	_, err = decompressAll(append(append([]byte{}, a...), b[:len(b)-1]...))
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+func TestStream_Checksum(t *testing.T) {
+	compressed := compressWithBlockSize(t, []byte("aaaaaaaabbbbcc"), DefaultBlockSize)
+
+	// The checksum of the only block is right before the tail
+	i := len(compressed) - (Uint32ByteSize + Uint64ByteSize + Uint16ByteSize) - 1
+	compressed[i] ^= 0xFF
+
+	_, err := decompressAll(compressed)
+	require.ErrorIs(t, err, ErrChecksumNotMatched)
+}
+
+func TestStream_Legacy(t *testing.T) {
+	want, err := os.ReadFile("../test/test_data1.txt")
+	require.Nil(t, err)
+	// The file was compressed with CRLF line endings
+	want = bytes.ReplaceAll(want, []byte("\n"), []byte("\r\n"))
+
+	compressed, err := os.ReadFile("../test/test_data1.txt.hf")
+	require.Nil(t, err)
+
+	var got bytes.Buffer
+	n, err := Decompress(&got, bytes.NewReader(compressed))
+	require.Nil(t, err)
+	require.Equal(t, int64(len(want)), n)
+	require.Equal(t, want, got.Bytes())
+}
+
+func TestStream_Version1(t *testing.T) {
+	want, err := os.ReadFile("../test/test_data1.txt")
+	require.Nil(t, err)
+	// The first 64 KiB, compressed before the canonical codes
+	want = want[:1<<16]
+
+	compressed, err := os.ReadFile("../test/test_data1_64k.txt.v1.hf")
+	require.Nil(t, err)
+
+	got, err := decompressAll(compressed)
+	require.Nil(t, err)
+	require.Equal(t, want, got)
+}
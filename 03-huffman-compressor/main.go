@@ -4,47 +4,71 @@ import (
 	"compressor/huffman"
 	"flag"
 	"fmt"
+	"io"
 	"os"
 )
 
 func main() {
 	performCompress := flag.Bool("compress", false, "compress given file")
 	performDecompress := flag.Bool("decompress", false, "decompress given file")
-	inputFile := flag.String("input", "", "input filename")
-	outputFile := flag.String("output", "", "output filename")
+	inputFile := flag.String("input", "-", "input filename, - for the standard input")
+	outputFile := flag.String("output", "-", "output filename, - for the standard output")
 
 	flag.Parse()
 
-	if *inputFile == "" {
-		fmt.Println("please specify the input filename")
-		os.Exit(1)
-	}
-	if *outputFile == "" {
-		fmt.Println("please specify the output filename")
-		os.Exit(1)
-	}
 	if *performCompress && *performDecompress || !*performCompress && !*performDecompress {
-		fmt.Println("compress flag or decompress should one set to true")
+		fmt.Fprintln(os.Stderr, "compress flag or decompress should one set to true")
 		os.Exit(1)
 	}
 
+	// The status goes to stderr, the standard output may be the compressed stream
 	if *performCompress {
-		fmt.Println("performing compression...")
-		err := huffman.CompressFile(*inputFile, *outputFile)
+		fmt.Fprintln(os.Stderr, "performing compression...")
+		err := run(huffman.Compress, *inputFile, *outputFile)
 		if err != nil {
-			fmt.Printf("compression failed: %v\n", err)
-		} else {
-			fmt.Println("compression ok")
+			fmt.Fprintf(os.Stderr, "compression failed: %v\n", err)
+			os.Exit(1)
 		}
+		fmt.Fprintln(os.Stderr, "compression ok")
 	}
 
 	if *performDecompress {
-		fmt.Println("performing decompression...")
-		err := huffman.DecompressFile(*inputFile, *outputFile)
+		fmt.Fprintln(os.Stderr, "performing decompression...")
+		err := run(huffman.Decompress, *inputFile, *outputFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "decompression failed: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Fprintln(os.Stderr, "decompression ok")
+	}
+}
+
+// run Opens the input and the output, an empty name or - is the standard input or output, and calls f
+func run(f func(io.Writer, io.Reader) (int64, error), input, output string) error {
+	src := io.Reader(os.Stdin)
+	if input != "" && input != "-" {
+		srcF, err := os.Open(input)
 		if err != nil {
-			fmt.Printf("decompression failed: %v\n", err)
-		} else {
-			fmt.Println("decompression ok")
+			return err
 		}
+		defer srcF.Close()
+		src = srcF
+	}
+
+	if output == "" || output == "-" {
+		_, err := f(os.Stdout, src)
+		return err
+	}
+
+	dstF, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f(dstF, src); err != nil {
+		dstF.Close()
+		return err
 	}
+
+	return dstF.Close()
 }